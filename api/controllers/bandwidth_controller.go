@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserBandwidthGet returns the active bandwidth limits.
+// GET /api/self/v1/bandwidth
+func UserBandwidthGet(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(tool.GetBandwidthLimits()))
+}
+
+// UserBandwidthSet replaces the bandwidth limits, applies them to running transfers and saves them to the config file.
+// PUT /api/self/v1/bandwidth
+func UserBandwidthSet(c *gin.Context) {
+	var request types.BandwidthConfig
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	if err := tool.UpdateBandwidthLimits(request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Failed to set bandwidth limits: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(tool.GetBandwidthLimits()))
+}
@@ -40,7 +40,7 @@ func (ctrl *CancelController) HandleCancel(c *gin.Context) {
 // HandleCancelV1Cancel handles V1 cancel request
 // POST /api/localsend/v1/cancel
 func (ctrl *CancelController) HandleCancelV1Cancel(c *gin.Context) {
-	remoteAddr := c.ClientIP()
+	remoteAddr := tool.ClientIP(c)
 	tool.DefaultLogger.Infof("[V1 Cancel] Received cancel request from IP: %s", remoteAddr)
 
 	sessionId := models.GetV1Session(remoteAddr)
@@ -11,7 +11,9 @@ import (
 	"github.com/moyoez/localsend-go/types"
 )
 
-// UserConfirmRecv handles confirm receive request
+// UserConfirmRecv handles confirm receive request.
+// Optional fileIds (comma-separated or repeated) accepts only those files of the offer; when it names none of
+// them, the request fails with 400 and the confirmation stays pending.
 // GET /api/self/v1/confirm-recv
 func UserConfirmRecv(c *gin.Context) {
 	sessionId := strings.TrimSpace(c.Query("sessionId"))
@@ -31,14 +33,27 @@ func UserConfirmRecv(c *gin.Context) {
 		return
 	}
 
+	var acceptedFiles []string
+	for _, raw := range c.QueryArray("fileIds") {
+		for fileID := range strings.SplitSeq(raw, ",") {
+			if fileID = strings.TrimSpace(fileID); fileID != "" {
+				acceptedFiles = append(acceptedFiles, fileID)
+			}
+		}
+	}
+
 	confirmCh, ok := models.GetConfirmRecvChannel(sessionId)
 	if !ok {
 		c.JSON(http.StatusNotFound, tool.FastReturnError("Session not found or expired"))
 		return
 	}
+	if confirmed && len(acceptedFiles) > 0 && !models.InboundSessionOffersAny(sessionId, acceptedFiles) {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid parameter: fileIds matches no offered file"))
+		return
+	}
 
 	select {
-	case confirmCh <- types.ConfirmResult{Confirmed: confirmed}:
+	case confirmCh <- types.ConfirmResult{Confirmed: confirmed, AcceptedFiles: acceptedFiles}:
 		models.DeleteConfirmRecvChannel(sessionId)
 		c.JSON(http.StatusOK, tool.FastReturnSuccess())
 	default:
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/boardcast"
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// UserDiscoveryDiagnostics returns per-interface discovery counters, the scan state and a loopback self-test
+// (an announcement sent and received by our own listeners). ?selfTest=false skips the self-test.
+// GET /api/self/v1/diagnostics/discovery
+func UserDiscoveryDiagnostics(c *gin.Context) {
+	selfTest := c.DefaultQuery("selfTest", "true") != "false"
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(boardcast.GetDiscoveryDiagnostics(selfTest)))
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/notify"
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// eventsHeartbeatInterval keeps idle event streams alive through proxies and detects gone clients.
+const eventsHeartbeatInterval = 15 * time.Second
+
+// UserEvents streams every notification as Server-Sent Events.
+// Filter with ?types=confirm_recv,upload_end; resume with the Last-Event-ID header or ?lastEventId=.
+// GET /api/self/v1/events
+func UserEvents(c *gin.Context) {
+	var filter map[string]struct{}
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			if filter == nil {
+				filter = make(map[string]struct{})
+			}
+			filter[t] = struct{}{}
+		}
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid last event id: "+lastEventID))
+			return
+		}
+		lastID = id
+	}
+
+	backlog, ch, cancel := notify.SubscribeEvents(lastID, lastEventID != "")
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	writeEvent := func(event notify.Event) error {
+		if filter != nil {
+			if _, ok := filter[event.Type]; !ok {
+				return nil
+			}
+		}
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Payload)
+		return err
+	}
+	// Tell EventSource clients how long to wait before reconnecting.
+	if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	for _, event := range backlog {
+		if err := writeEvent(event); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID.
+				return
+			}
+			if err := writeEvent(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
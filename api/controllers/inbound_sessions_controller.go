@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// UserInboundSessionsList returns the upload sessions being received or waiting for confirmation, with their
+// sender, files and state.
+// GET /api/self/v1/inbound-sessions
+func UserInboundSessionsList(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(models.ListInboundSessions()))
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// UserKnownDevicesList returns every persisted known device; devices not rediscovered yet are offline.
+// GET /api/self/v1/known-devices
+func UserKnownDevicesList(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(share.ListKnownDevices()))
+}
+
+// UserKnownDevicesDelete forgets a known device.
+// DELETE /api/self/v1/known-devices?fingerprint=xxx
+func UserKnownDevicesDelete(c *gin.Context) {
+	fingerprint := c.Query("fingerprint")
+	if fingerprint == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("fingerprint is required"))
+		return
+	}
+	removed, err := share.ForgetKnownDevice(fingerprint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to forget known device: "+err.Error()))
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Known device not found"))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccess())
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserTransferProgress returns the byte-level progress (bytes, throughput, ETA per session and file) of running
+// and recently ended transfers. ?direction=inbound|outbound and ?sessionId= filter the list.
+// GET /api/self/v1/transfer-progress
+func UserTransferProgress(c *gin.Context) {
+	direction := c.Query("direction")
+	switch direction {
+	case "", types.TransferInbound, types.TransferOutbound:
+	default:
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid direction (use inbound or outbound)"))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(transfer.ListProgress(direction, c.Query("sessionId"))))
+}
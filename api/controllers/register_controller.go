@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"io"
-	"net"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -50,7 +49,7 @@ func (ctrl *RegisterController) HandleRegister(c *gin.Context) {
 	}
 
 	// Add the registering device to scan-current so it appears in device list
-	if host, _, err := net.SplitHostPort(c.Request.RemoteAddr); err == nil && host != "" && incoming.Fingerprint != "" {
+	if host := tool.PeerIP(c); host != "" && incoming.Fingerprint != "" {
 		protocol := incoming.Protocol
 		if protocol == "" {
 			// use self protocol
@@ -59,6 +58,7 @@ func (ctrl *RegisterController) HandleRegister(c *gin.Context) {
 		}
 		share.SetUserScanCurrent(incoming.Fingerprint, types.UserScanCurrentItem{
 			Ipaddress: host,
+			Source:    types.DeviceSourceRegister,
 			VersionMessage: types.VersionMessage{
 				Alias:       incoming.Alias,
 				Version:     incoming.Version,
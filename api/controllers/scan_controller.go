@@ -32,9 +32,15 @@ func UserScanCurrent(c *gin.Context) {
 	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(values))
 }
 
-// UserScanNow triggers scan-now: HTTP scan only. Clears device list, runs HTTP scan, returns current devices; normal (mixed) auto scan continues in background.
+// UserScanNow triggers scan-now by scan mode. Clears device list, runs an HTTP scan (http/mixed) or sends an announcement
+// or query (udp/mdns), returns current devices; normal auto scan continues in background. In off mode it answers
+// 409 and keeps the device list.
 // GET /api/self/v1/scan-now
 func UserScanNow(c *gin.Context) {
+	if config := boardcast.GetScanConfig(); config != nil && config.Mode == types.ScanModeOff {
+		c.JSON(http.StatusConflict, tool.FastReturnError("Scanning is disabled (scan mode off)"))
+		return
+	}
 	share.ClearUserScanCurrent()
 	err := boardcast.ScanNow()
 	if err != nil {
@@ -52,3 +58,78 @@ func UserScanNow(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(values))
 }
+
+// UserScanTargetsGet returns the configured HTTP scan targets (CIDR, range or single IPv4).
+// GET /api/self/v1/scan-targets
+func UserScanTargetsGet(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(tool.ListScanTargets()))
+}
+
+// UserScanTargetsSet replaces the configured HTTP scan targets and saves them to the config file.
+// PUT /api/self/v1/scan-targets
+func UserScanTargetsSet(c *gin.Context) {
+	var request types.UserScanTargetsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	if err := tool.SetScanTargets(request.Targets); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Failed to set scan targets: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(tool.ListScanTargets()))
+}
+
+// UserScanProgress returns the progress of the HTTP scan sweep.
+// GET /api/self/v1/scan-progress
+func UserScanProgress(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(boardcast.GetHTTPScanProgress()))
+}
+
+// UserScanModeGet returns the current scan mode.
+// GET /api/self/v1/scan-mode
+func UserScanModeGet(c *gin.Context) {
+	config := boardcast.GetScanConfig()
+	if config == nil {
+		c.JSON(http.StatusServiceUnavailable, tool.FastReturnError("Scan config not set"))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.ScanModeStatus{
+		Mode:            config.Mode.String(),
+		AutoScanRunning: boardcast.IsAutoScanRunning(),
+	}))
+}
+
+// UserScanModeSet switches the scan mode at runtime (udp|http|mixed|mdns|off) and saves it to the config file.
+// A failed save answers 500 with the (switched) scan mode status as data.
+// PUT /api/self/v1/scan-mode
+func UserScanModeSet(c *gin.Context) {
+	var request types.UserScanModeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	mode, err := types.ParseScanMode(request.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError(err.Error()))
+		return
+	}
+	if err := boardcast.SetScanMode(mode); err != nil {
+		c.JSON(http.StatusServiceUnavailable, tool.FastReturnError("Failed to switch scan mode: "+err.Error()))
+		return
+	}
+	if err := tool.SetScanModeConfig(mode); err != nil {
+		tool.DefaultLogger.Warnf("Failed to save scan mode: %v", err)
+		// The mode is active until restart; report it along with the failure.
+		c.JSON(http.StatusInternalServerError, tool.FastReturnErrorWithData("Scan mode switched but not saved: "+err.Error(),
+			map[string]any{"mode": mode.String(), "autoScanRunning": boardcast.IsAutoScanRunning()}))
+		return
+	}
+	UserScanModeGet(c)
+}
+
+// UserRegisterStats returns the counters of the /register answers to multicast announcements.
+// GET /api/self/v1/register-stats
+func UserRegisterStats(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(boardcast.GetRegisterResponseStats()))
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/boardcast"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserStaticPeersList returns the configured static peers with their online status.
+// GET /api/self/v1/static-peers
+func UserStaticPeersList(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(boardcast.ListStaticPeerStatuses()))
+}
+
+// UserStaticPeersAdd adds (or replaces) a static peer and polls it immediately.
+// POST /api/self/v1/static-peers
+func UserStaticPeersAdd(c *gin.Context) {
+	var request types.StaticPeerEntry
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	entry, err := tool.AddStaticPeer(request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Failed to add static peer: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(boardcast.PollStaticPeer(entry)))
+}
+
+// UserStaticPeersDelete removes a static peer.
+// DELETE /api/self/v1/static-peers?host=10.1.2.3&port=53317
+func UserStaticPeersDelete(c *gin.Context) {
+	host := c.Query("host")
+	if host == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("host is required"))
+		return
+	}
+	port := 0
+	if portStr := c.Query("port"); portStr != "" {
+		var err error
+		if port, err = strconv.Atoi(portStr); err != nil {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid port: "+portStr))
+			return
+		}
+	}
+	removed, err := tool.RemoveStaticPeer(host, port)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to remove static peer: "+err.Error()))
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Static peer not found"))
+		return
+	}
+	boardcast.ForgetStaticPeer(host, port)
+	c.JSON(http.StatusOK, tool.FastReturnSuccess())
+}
@@ -2,16 +2,31 @@ package controllers
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/moyoez/localsend-go/api/defaults"
 	"github.com/moyoez/localsend-go/api/models"
-	"github.com/moyoez/localsend-go/boardcast"
 	"github.com/moyoez/localsend-go/notify"
 	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
 
+// uploadOffsetHeader carries the number of bytes received so far in resumable upload errors.
+const uploadOffsetHeader = "Upload-Offset"
+
+// uploadResumeTimeout is how long an interrupted file stays pending for the sender to resume it; after that it
+// fails, so the session still completes.
+const uploadResumeTimeout = 2 * time.Minute
+
+// refuseWithRetryAfter answers a prepare-upload refused by an inbound session limit, telling the sender when to retry.
+func refuseWithRetryAfter(c *gin.Context, status int, errorMsg string) {
+	retryAfter := models.InboundSessionRetryAfter()
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(status, tool.FastReturnErrorWithData(errorMsg, map[string]any{"retryAfter": retryAfter}))
+}
+
 type UploadController struct{}
 
 func NewUploadController() *UploadController {
@@ -37,7 +52,7 @@ func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
 	tool.DefaultLogger.Infof("[PrepareUpload] Received prepare-upload request from %s (pin: %s)", request.Info.Alias, pin)
 	tool.DefaultLogger.Infof("[PrepareUpload] Number of files: %d", len(request.Files))
 
-	response, callbackErr := defaults.DefaultOnPrepareUpload(request, pin)
+	response, callbackErr := defaults.DefaultOnPrepareUpload(request, pin, tool.ClientIP(c))
 	if callbackErr != nil {
 		tool.DefaultLogger.Errorf("[PrepareUpload] Prepare-upload callback error: %v", callbackErr)
 		errorMsg := callbackErr.Error()
@@ -55,10 +70,16 @@ func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
 			c.JSON(http.StatusForbidden, tool.FastReturnError(errorMsg))
 			return
 		case "blocked by another session":
-			c.JSON(http.StatusConflict, tool.FastReturnError(errorMsg))
+			refuseWithRetryAfter(c, http.StatusConflict, errorMsg)
 			return
 		case "too many requests":
-			c.JSON(http.StatusTooManyRequests, tool.FastReturnError(errorMsg))
+			refuseWithRetryAfter(c, http.StatusTooManyRequests, errorMsg)
+			return
+		case "insufficient storage":
+			c.JSON(http.StatusInsufficientStorage, tool.FastReturnError(errorMsg))
+			return
+		case "quota exceeded":
+			c.JSON(http.StatusRequestEntityTooLarge, tool.FastReturnError(errorMsg))
 			return
 		default:
 			c.JSON(http.StatusInternalServerError, tool.FastReturnError(errorMsg))
@@ -74,17 +95,17 @@ func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
 
 	// Initialize session stats and send upload start notification (single notification for all files)
 	if response.SessionId != "" {
-		// Pause scanning during file transfer
-		boardcast.PauseScan()
-
 		// Initialize upload statistics for this session
-		models.InitSessionStats(response.SessionId, len(request.Files))
+		models.InitSessionStats(response.SessionId, len(response.Files))
 
 		// Collect file info for notification (limit to MaxNotifyFiles to control payload size)
-		maxFiles := min(len(request.Files), notify.MaxNotifyFiles)
+		maxFiles := min(len(response.Files), notify.MaxNotifyFiles)
 		filesList := make([]map[string]any, 0, maxFiles)
 		var totalSize int64
 		for fileID, fileInfo := range request.Files {
+			if _, accepted := response.Files[fileID]; !accepted {
+				continue
+			}
 			totalSize += fileInfo.Size
 			if len(filesList) < notify.MaxNotifyFiles {
 				filesList = append(filesList, map[string]any{
@@ -111,7 +132,7 @@ func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
 			} else {
 				tool.DefaultLogger.Infof("[Notify] Successfully sent upload_start notification for session: %s", sessionId)
 			}
-		}(response.SessionId, filesList, len(request.Files), totalSize)
+		}(response.SessionId, filesList, len(response.Files), totalSize)
 
 		tool.DefaultLogger.Infof("[PrepareUpload] Successfully prepared upload session: %s", response.SessionId)
 	}
@@ -137,11 +158,11 @@ func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
 		return
 	}
 
-	remoteAddr := c.ClientIP()
+	remoteAddr := tool.ClientIP(c)
 	tool.DefaultLogger.Infof("[V1 SendRequest] Received send-request from %s (IP: %s)", request.Info.Alias, remoteAddr)
 	tool.DefaultLogger.Infof("[V1 SendRequest] Number of files: %d", len(request.Files))
 
-	response, callbackErr := defaults.DefaultOnPrepareUpload(request, "")
+	response, callbackErr := defaults.DefaultOnPrepareUpload(request, "", remoteAddr)
 	if callbackErr != nil {
 		tool.DefaultLogger.Errorf("[V1 SendRequest] Callback error: %v", callbackErr)
 		errorMsg := callbackErr.Error()
@@ -150,10 +171,16 @@ func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
 			c.JSON(http.StatusForbidden, tool.FastReturnError(errorMsg))
 			return
 		case "blocked by another session":
-			c.JSON(http.StatusConflict, tool.FastReturnError(errorMsg))
+			refuseWithRetryAfter(c, http.StatusConflict, errorMsg)
 			return
 		case "too many requests":
-			c.JSON(http.StatusTooManyRequests, tool.FastReturnError(errorMsg))
+			refuseWithRetryAfter(c, http.StatusTooManyRequests, errorMsg)
+			return
+		case "insufficient storage":
+			c.JSON(http.StatusInsufficientStorage, tool.FastReturnError(errorMsg))
+			return
+		case "quota exceeded":
+			c.JSON(http.StatusRequestEntityTooLarge, tool.FastReturnError(errorMsg))
 			return
 		default:
 			c.JSON(http.StatusInternalServerError, tool.FastReturnError(errorMsg))
@@ -163,19 +190,19 @@ func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
 
 	// Store IP -> sessionId mapping for V1 (since V1 doesn't use sessionId in subsequent requests)
 	if response != nil && response.SessionId != "" {
-		// Pause scanning during file transfer
-		boardcast.PauseScan()
-
 		models.StoreV1Session(remoteAddr, response.SessionId)
 
 		// Initialize upload statistics for this session
-		models.InitSessionStats(response.SessionId, len(request.Files))
+		models.InitSessionStats(response.SessionId, len(response.Files))
 
 		// Collect file info for notification (limit to MaxNotifyFiles to control payload size)
-		maxFiles := min(len(request.Files), notify.MaxNotifyFiles)
+		maxFiles := min(len(response.Files), notify.MaxNotifyFiles)
 		filesList := make([]map[string]any, 0, maxFiles)
 		var totalSize int64
 		for fileID, fileInfo := range request.Files {
+			if _, accepted := response.Files[fileID]; !accepted {
+				continue
+			}
 			totalSize += fileInfo.Size
 			if len(filesList) < notify.MaxNotifyFiles {
 				filesList = append(filesList, map[string]any{
@@ -202,7 +229,7 @@ func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
 			} else {
 				tool.DefaultLogger.Infof("[V1 Notify] Successfully sent upload_start notification for session: %s", sessionId)
 			}
-		}(response.SessionId, filesList, len(request.Files), totalSize)
+		}(response.SessionId, filesList, len(response.Files), totalSize)
 
 		tool.DefaultLogger.Infof("[V1 SendRequest] Successfully prepared session: %s for IP: %s", response.SessionId, remoteAddr)
 	}
@@ -224,7 +251,12 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 		return
 	}
 
-	remoteAddr := c.ClientIP()
+	offset, resumable, ok := parseUploadOffset(c)
+	if !ok {
+		return
+	}
+
+	remoteAddr := tool.ClientIP(c)
 	// V1 uses IP address to determine session
 	sessionId := models.GetV1Session(remoteAddr)
 	if sessionId == "" {
@@ -238,44 +270,15 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 	// Get file info before processing (needed for both success and failure cases)
 	fileInfo, hasFileInfo := models.LookupFileInfo(sessionId, fileId)
 
-	uploadErr := defaults.DefaultOnUpload(sessionId, fileId, token, c.Request.Body, remoteAddr)
+	uploadErr := defaults.DefaultOnUpload(sessionId, fileId, token, offset, resumable, c.Request.Body, remoteAddr)
 	if uploadErr != nil {
 		tool.DefaultLogger.Errorf("[V1 Send] Upload callback error: %v", uploadErr)
-
-		// Mark file as failed and check if all files are done
-		remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, false)
-		tool.DefaultLogger.Infof("[V1 Send] File failed: %s, remaining files: %d, isLast: %v", fileId, remaining, isLast)
-
-		if isLast {
-			boardcast.ResumeScan()
-		}
-		// Send notification when all files are processed (even if some failed)
-		if isLast && stats != nil {
-			go func(sid string, stats *types.SessionUploadStats, remoteAddr string) {
-				savePaths := models.GetSessionSavePaths(sid)
-				savedFileNames := tool.BuildSavedFileNames(savePaths)
-				models.RemoveV1Session(remoteAddr)
-				tool.DefaultLogger.Infof("[V1 Notify] Sending upload_end notification (all files processed): sessionId=%s, success=%d, failed=%d",
-					sid, stats.SuccessFiles, stats.FailedFiles)
-				data := map[string]any{
-					"totalFiles":             stats.TotalFiles,
-					"successFiles":           stats.SuccessFiles,
-					"failedFiles":            stats.FailedFiles,
-					"failedFileIds":          stats.FailedFileIds,
-					"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-					"uploadFolder":           models.DefaultUploadFolder,
-					"savePaths":              savePaths,
-					"savedFileNames":         savedFileNames,
-				}
-				if err := notify.SendUploadNotification(types.NotifyTypeUploadEnd, sid, "", data); err != nil {
-					tool.DefaultLogger.Errorf("[V1 Notify] Failed to send upload_end notification: %v", err)
-				}
-
-				models.CleanupSessionStats(sid)
-				models.RemoveUploadSession(sid)
-			}(sessionId, stats, remoteAddr)
+		if handleResumableUploadError(c, sessionId, fileId, remoteAddr, true, uploadErr) {
+			return
 		}
 
+		failUploadedFile(sessionId, fileId, remoteAddr, true)
+
 		errorMsg := uploadErr.Error()
 		switch errorMsg {
 		case "Invalid token or IP address":
@@ -300,9 +303,6 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 	remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, true)
 	tool.DefaultLogger.Infof("[V1 Send] File completed: %s, remaining files: %d, isLast: %v", fileInfo.FileName, remaining, isLast)
 
-	if isLast {
-		boardcast.ResumeScan()
-	}
 	if isLast && stats != nil {
 		go func(sid, fid string, fileInfo types.FileInfo, stats *types.SessionUploadStats) {
 			savePaths := models.GetSessionSavePaths(sid)
@@ -329,6 +329,7 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 			if err := notify.SendUploadNotification(types.NotifyTypeUploadEnd, sid, fid, data); err != nil {
 				tool.DefaultLogger.Errorf("[V1 Notify] Failed to send upload_end notification: %v", err)
 			}
+			defaults.DefaultOnSessionComplete(sid, remoteAddr, stats, savePaths)
 			models.CleanupSessionStats(sid)
 			models.RemoveUploadSession(sid)
 		}(sessionId, fileId, fileInfo, stats)
@@ -357,47 +358,27 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 		models.MarkSessionValidated(sessionId)
 	}
 
-	remoteAddr := c.ClientIP()
+	offset, resumable, ok := parseUploadOffset(c)
+	if !ok {
+		return
+	}
+
+	remoteAddr := tool.ClientIP(c)
 	tool.DefaultLogger.Infof("[Upload] Received upload request: sessionId=%s, fileId=%s, token=%s, remoteAddr=%s", sessionId, fileId, token, remoteAddr)
 	tool.DefaultLogger.Debugf("[Upload] Content-Type: %s", c.GetHeader("Content-Type"))
 
 	// Get file info before processing (needed for both success and failure cases)
 	fileInfo, hasFileInfo := models.LookupFileInfo(sessionId, fileId)
 
-	uploadErr := defaults.DefaultOnUpload(sessionId, fileId, token, c.Request.Body, remoteAddr)
+	uploadErr := defaults.DefaultOnUpload(sessionId, fileId, token, offset, resumable, c.Request.Body, remoteAddr)
 	if uploadErr != nil {
 		tool.DefaultLogger.Errorf("[Upload] Upload callback error: %v", uploadErr)
-
-		remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, false)
-		tool.DefaultLogger.Infof("[Upload] File failed: %s, remaining files: %d, isLast: %v", fileId, remaining, isLast)
-
-		if isLast {
-			boardcast.ResumeScan()
-		}
-		if isLast && stats != nil {
-			go func(sid string, stats *types.SessionUploadStats) {
-				savePaths := models.GetSessionSavePaths(sid)
-				savedFileNames := tool.BuildSavedFileNames(savePaths)
-				tool.DefaultLogger.Infof("[Notify] Sending upload_end notification (all files processed): sessionId=%s, success=%d, failed=%d",
-					sid, stats.SuccessFiles, stats.FailedFiles)
-				data := map[string]any{
-					"totalFiles":             stats.TotalFiles,
-					"successFiles":           stats.SuccessFiles,
-					"failedFiles":            stats.FailedFiles,
-					"failedFileIds":          stats.FailedFileIds,
-					"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-					"uploadFolder":           models.DefaultUploadFolder,
-					"savePaths":              savePaths,
-					"savedFileNames":         savedFileNames,
-				}
-				if err := notify.SendUploadNotification(types.NotifyTypeUploadEnd, sid, "", data); err != nil {
-					tool.DefaultLogger.Errorf("[Notify] Failed to send upload_end notification: %v", err)
-				}
-				models.CleanupSessionStats(sid)
-				models.RemoveUploadSession(sid)
-			}(sessionId, stats)
+		if handleResumableUploadError(c, sessionId, fileId, remoteAddr, false, uploadErr) {
+			return
 		}
 
+		failUploadedFile(sessionId, fileId, remoteAddr, false)
+
 		errorMsg := uploadErr.Error()
 		switch errorMsg {
 		case "Invalid token or IP address":
@@ -422,9 +403,6 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 	remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, true)
 	tool.DefaultLogger.Infof("[Upload] File completed: %s, remaining files: %d, isLast: %v", fileInfo.FileName, remaining, isLast)
 
-	if isLast {
-		boardcast.ResumeScan()
-	}
 	if isLast && stats != nil {
 		go func(sid, fid string, fileInfo types.FileInfo, stats *types.SessionUploadStats) {
 			savePaths := models.GetSessionSavePaths(sid)
@@ -453,6 +431,7 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 			} else {
 				tool.DefaultLogger.Infof("[Notify] Successfully sent upload_end notification for session: %s", sid)
 			}
+			defaults.DefaultOnSessionComplete(sid, remoteAddr, stats, savePaths)
 			models.CleanupSessionStats(sid)
 			models.RemoveUploadSession(sid)
 		}(sessionId, fileId, fileInfo, stats)
@@ -460,3 +439,87 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 
 	c.Status(http.StatusOK)
 }
+
+// parseUploadOffset reads the optional ?offset= used to resume an interrupted upload (protocol extension).
+// A sender passing it, even as offset=0, can resume; interrupted files of other senders fail right away.
+// It writes a 400 response and returns false when the value is invalid.
+func parseUploadOffset(c *gin.Context) (offset int64, resumable bool, ok bool) {
+	raw := c.Query("offset")
+	if raw == "" {
+		return 0, false, true
+	}
+	offset, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid offset"))
+		return 0, false, false
+	}
+	return offset, true, true
+}
+
+// handleResumableUploadError answers upload errors that leave the file pending so the sender can retry.
+// The Upload-Offset header tells the sender where to resume (?offset=). An interrupted file that is not resumed
+// within uploadResumeTimeout is failed. Returns false for other errors.
+func handleResumableUploadError(c *gin.Context, sessionId, fileId, remoteAddr string, v1 bool, uploadErr error) bool {
+	var status int
+	switch uploadErr.Error() {
+	case "upload interrupted":
+		status = http.StatusInternalServerError
+		models.ExpireUnresumedFile(sessionId, fileId, uploadResumeTimeout, func() {
+			tool.DefaultLogger.Warnf("File %s not resumed within %v (sessionId=%s), marking it failed", fileId, uploadResumeTimeout, sessionId)
+			if partPath, ok := models.GetPartFilePath(sessionId, fileId); ok {
+				models.RemovePartFile(sessionId, fileId, partPath)
+			}
+			failUploadedFile(sessionId, fileId, remoteAddr, v1)
+		})
+	case "offset mismatch":
+		status = http.StatusRequestedRangeNotSatisfiable
+	case "upload in progress":
+		status = http.StatusConflict
+	default:
+		return false
+	}
+	c.Header(uploadOffsetHeader, strconv.FormatInt(defaults.UploadOffset(sessionId, fileId), 10))
+	c.JSON(status, tool.FastReturnError(uploadErr.Error()))
+	return true
+}
+
+// failUploadedFile marks a file as failed; once it was the last file of the session, it sends upload_end, runs the
+// session completion callback and removes the session. v1 selects the log prefixes and v1 session cleanup.
+func failUploadedFile(sessionId, fileId, remoteAddr string, v1 bool) {
+	logPrefix, notifyPrefix := "[Upload]", "[Notify]"
+	if v1 {
+		logPrefix, notifyPrefix = "[V1 Send]", "[V1 Notify]"
+	}
+	remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, false)
+	tool.DefaultLogger.Infof("%s File failed: %s, remaining files: %d, isLast: %v", logPrefix, fileId, remaining, isLast)
+
+	// Send notification when all files are processed (even if some failed)
+	if isLast && stats != nil {
+		go func(sid string, stats *types.SessionUploadStats) {
+			savePaths := models.GetSessionSavePaths(sid)
+			savedFileNames := tool.BuildSavedFileNames(savePaths)
+			if v1 {
+				models.RemoveV1Session(remoteAddr)
+			}
+			tool.DefaultLogger.Infof("%s Sending upload_end notification (all files processed): sessionId=%s, success=%d, failed=%d",
+				notifyPrefix, sid, stats.SuccessFiles, stats.FailedFiles)
+			data := map[string]any{
+				"totalFiles":             stats.TotalFiles,
+				"successFiles":           stats.SuccessFiles,
+				"failedFiles":            stats.FailedFiles,
+				"failedFileIds":          stats.FailedFileIds,
+				"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
+				"uploadFolder":           models.DefaultUploadFolder,
+				"savePaths":              savePaths,
+				"savedFileNames":         savedFileNames,
+			}
+			if err := notify.SendUploadNotification(types.NotifyTypeUploadEnd, sid, "", data); err != nil {
+				tool.DefaultLogger.Errorf("%s Failed to send upload_end notification: %v", notifyPrefix, err)
+			}
+
+			defaults.DefaultOnSessionComplete(sid, remoteAddr, stats, savePaths)
+			models.CleanupSessionStats(sid)
+			models.RemoveUploadSession(sid)
+		}(sessionId, stats)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"maps"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -85,8 +84,9 @@ func IsUserUploadSessionCancelled(sessionId string) bool {
 
 func resolveFastSenderIP(fullIP, ipSuffix string) (string, error) {
 	if fullIP != "" {
-		if ip := net.ParseIP(fullIP); ip != nil {
-			return fullIP, nil
+		// Accepts IPv4, IPv6, bracketed ([fe80::1]) and zoned (fe80::1%eth0) forms.
+		if ip, err := tool.NormalizeIPString(fullIP); err == nil {
+			return ip, nil
 		}
 		return "", errors.New("invalid IP address format")
 	}
@@ -124,6 +124,7 @@ func UserPrepareUpload(c *gin.Context) {
 		}
 		targetItem = types.UserScanCurrentItem{
 			Ipaddress: targetIP,
+			Source:    types.DeviceSourceFastSender,
 			VersionMessage: types.VersionMessage{
 				Alias:       deviceInfo.Alias,
 				Version:     deviceInfo.Version,
@@ -241,9 +242,10 @@ func UserPrepareUpload(c *gin.Context) {
 		Files: filesMap,
 	}
 
-	targetAddr := &net.UDPAddr{
-		IP:   net.ParseIP(targetItem.Ipaddress).To4(),
-		Port: targetItem.Port,
+	targetAddr, err := tool.ParseTargetUDPAddr(targetItem.Ipaddress, targetItem.Port)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid target address: "+err.Error()))
+		return
 	}
 
 	prepareResponse, err := transfer.ReadyToUploadTo(targetAddr, &targetItem.VersionMessage, prepareRequest, pin)
@@ -277,6 +279,12 @@ func UserPrepareUpload(c *gin.Context) {
 	}
 	UserUploadSessions.Set(prepareResponse.SessionId, sessionInfo)
 	CreateUserUploadSessionContext(prepareResponse.SessionId)
+	share.RecordKnownDeviceSent(targetItem, len(prepareResponse.Files))
+	acceptedFiles := make(map[string]types.FileInfo, len(prepareResponse.Files))
+	for fileID := range prepareResponse.Files {
+		acceptedFiles[fileID] = filesMap[fileID]
+	}
+	transfer.StartProgress(types.TransferOutbound, prepareResponse.SessionId, targetItem.Alias, acceptedFiles)
 
 	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.PrepareUploadResponse{
 		SessionId: prepareResponse.SessionId,
@@ -372,11 +380,12 @@ func UserUpload(c *gin.Context) {
 		ctx = context.Background()
 	}
 	fileReader = bytes.NewReader(fileData)
-	targetAddr := &net.UDPAddr{
-		IP:   net.ParseIP(sessionInfo.Target.Ipaddress).To4(),
-		Port: sessionInfo.Target.Port,
+	targetAddr, err := tool.ParseTargetUDPAddr(sessionInfo.Target.Ipaddress, sessionInfo.Target.Port)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Invalid target address: "+err.Error()))
+		return
 	}
-	err := transfer.UploadFileWithContext(ctx, targetAddr, &sessionInfo.Target.VersionMessage, sessionId, fileId, token, fileReader)
+	err = transfer.UploadFileWithContext(ctx, targetAddr, &sessionInfo.Target.VersionMessage, sessionId, fileId, token, fileReader)
 	if err != nil {
 		if ctx.Err() != nil {
 			c.JSON(http.StatusConflict, tool.FastReturnError("Upload cancelled"))
@@ -466,9 +475,10 @@ func UserUploadBatch(c *gin.Context) {
 		Failed:  0,
 		Results: make([]types.UserUploadItemResult, 0, len(request.Files)),
 	}
-	targetAddr := &net.UDPAddr{
-		IP:   net.ParseIP(sessionInfo.Target.Ipaddress).To4(),
-		Port: sessionInfo.Target.Port,
+	targetAddr, err := tool.ParseTargetUDPAddr(sessionInfo.Target.Ipaddress, sessionInfo.Target.Port)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Invalid target address: "+err.Error()))
+		return
 	}
 
 	for _, fileItem := range request.Files {
@@ -562,12 +572,11 @@ func UserCancelUpload(c *gin.Context) {
 		return
 	}
 	CancelUserUploadSession(sessionId)
+	transfer.EndProgress(types.TransferOutbound, sessionId)
 	boardcast.ResumeScan()
-	targetAddr := &net.UDPAddr{
-		IP:   net.ParseIP(sessionInfo.Target.Ipaddress).To4(),
-		Port: sessionInfo.Target.Port,
-	}
-	if err := transfer.CancelSession(targetAddr, &sessionInfo.Target.VersionMessage, sessionId); err != nil {
+	if targetAddr, err := tool.ParseTargetUDPAddr(sessionInfo.Target.Ipaddress, sessionInfo.Target.Port); err != nil {
+		tool.DefaultLogger.Warnf("[CancelUpload] Invalid target address: %v", err)
+	} else if err := transfer.CancelSession(targetAddr, &sessionInfo.Target.VersionMessage, sessionId); err != nil {
 		tool.DefaultLogger.Warnf("[CancelUpload] Failed to send cancel request to target: %v", err)
 	}
 	c.JSON(http.StatusOK, tool.FastReturnSuccess())
@@ -4,16 +4,20 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/moyoez/localsend-go/api/models"
 	"github.com/moyoez/localsend-go/notify"
+	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
 	"github.com/moyoez/localsend-go/types"
 )
 
@@ -25,7 +29,8 @@ func DefaultOnRegister(remote *types.VersionMessage) error {
 }
 
 // DefaultOnPrepareUpload is the default callback for prepare-upload.
-func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*types.PrepareUploadResponse, error) {
+// remoteAddr is the sender's IP, matched by receivePolicy rules and used to verify a claimed fingerprint before auto-accepting.
+func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string, remoteAddr string) (*types.PrepareUploadResponse, error) {
 	tool.DefaultLogger.Infof("Received file transfer prepare request: from %s, file count: %d, PIN: %s",
 		request.Info.Alias, len(request.Files), pin)
 
@@ -56,6 +61,20 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 		return nil, fmt.Errorf("invalid PIN")
 	}
 
+	// The claimed fingerprint is checked at most once, and only if a rule or autoSaveFromFavorites depends on it.
+	senderVerified := sync.OnceValue(func() bool {
+		if err := transfer.VerifySender(remoteAddr, &request.Info); err != nil {
+			tool.DefaultLogger.Errorf("Fingerprint %s of %s at %s not verified: %v",
+				request.Info.Fingerprint, request.Info.Alias, remoteAddr, err)
+			return false
+		}
+		return true
+	})
+	decision := decideReceivePolicy(request, remoteAddr, senderVerified)
+	if decision.Action == types.ReceiveActionReject {
+		return nil, fmt.Errorf("rejected")
+	}
+
 	// Text-only message: single file, text/plain, with preview — show dialog, wait for user dismiss, then return 204 (no upload)
 	if len(request.Files) == 1 {
 		for _, info := range request.Files {
@@ -64,6 +83,16 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 				if request.Info.Alias != "" {
 					title = fmt.Sprintf("From %s", request.Info.Alias)
 				}
+				notify.RunReceiveHooks(types.ReceiveHookPayload{
+					Event:             types.ReceiveHookTextReceived,
+					SenderAlias:       request.Info.Alias,
+					SenderFingerprint: request.Info.Fingerprint,
+					SenderIP:          remoteAddr,
+					FileName:          info.FileName,
+					FileType:          info.FileType,
+					Size:              info.Size,
+					Text:              info.Preview,
+				})
 				textDismissSessionId := tool.GenerateRandomUUID()
 				dismissCh := make(chan struct{}, 1)
 				models.SetTextReceivedDismissChannel(textDismissSessionId, dismissCh)
@@ -85,24 +114,68 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 		}
 	}
 
-	programConfig := tool.GetProgramConfigStatus()
-	needConfirmation := !programConfig.AutoSave
-	if needConfirmation && programConfig.AutoSaveFromFavorites {
-		if tool.IsFavorite(request.Info.Fingerprint) {
-			tool.DefaultLogger.Infof("Auto-accepting from favorite device: %s (fingerprint: %s)", request.Info.Alias, request.Info.Fingerprint)
-			needConfirmation = false
+	accepted := request.Files
+	if decision.Action == types.ReceiveActionAcceptSubset {
+		accepted = make(map[string]types.FileInfo, len(decision.AcceptedFiles))
+		for _, fileID := range decision.AcceptedFiles {
+			accepted[fileID] = request.Files[fileID]
 		}
 	}
 
+	senderFavorite := sync.OnceValue(func() bool {
+		return tool.IsFavorite(request.Info.Fingerprint) && senderVerified()
+	})
+	destinations := make(map[string]types.ReceiveDestination, len(accepted))
+	roots := make(map[string]string, len(accepted))
+	for fileID, info := range accepted {
+		destinations[fileID] = tool.ResolveReceiveDestination(info, request.Info, remoteAddr, askSession,
+			models.DefaultUploadFolder, models.DoNotMakeSessionFolder, senderFavorite)
+		roots[fileID] = destinations[fileID].Root
+		tool.DefaultLogger.Debugf("Receive routing: file %s (%s) -> %s by route %s",
+			fileID, info.FileName, destinations[fileID].Dir, destinations[fileID].Route)
+	}
+
+	// Refuse (or trim) before asking the user, so a transfer never fails halfway on a full disk.
+	// A claimed fingerprint could be anybody's, so per-sender quotas count unverified senders by IP address.
+	quotaSender := remoteAddr
+	if tool.HasSenderQuota() && senderVerified() {
+		quotaSender = request.Info.Fingerprint
+	}
+	fitting, storageWarning, err := tool.CheckReceiveStorage(askSession, accepted, roots, quotaSender)
+	if err != nil {
+		return nil, err
+	}
+	if len(fitting) < len(accepted) {
+		trimmed := make(map[string]types.FileInfo, len(fitting))
+		for _, fileID := range fitting {
+			trimmed[fileID] = accepted[fileID]
+		}
+		accepted = trimmed
+	}
+
+	// The session holds a slot from now on, also while the user is asked; it is freed here unless it starts receiving.
+	if err := models.BeginInboundSession(askSession, request.Info, remoteAddr, accepted); err != nil {
+		tool.ReleaseReceiveReservation(askSession)
+		return nil, err
+	}
+	admitted := false
+	defer func() {
+		if !admitted {
+			models.EndInboundSession(askSession)
+		}
+	}()
+
+	needConfirmation := decision.Action == types.ReceiveActionAsk
+
 	if needConfirmation {
 		confirmCh := make(chan types.ConfirmResult, 1)
 		models.SetConfirmRecvChannel(askSession, confirmCh)
 		defer models.DeleteConfirmRecvChannel(askSession)
 
 		// Only collect first MaxNotifyFiles for notify payload, keep full FileInfo
-		maxFiles := min(len(request.Files), notify.MaxNotifyFiles)
+		maxFiles := min(len(accepted), notify.MaxNotifyFiles)
 		files := make([]types.FileInfo, 0, maxFiles)
-		for _, info := range request.Files {
+		for _, info := range accepted {
 			if len(files) >= notify.MaxNotifyFiles {
 				break
 			}
@@ -116,13 +189,18 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 			Data: map[string]any{
 				"sessionId":  askSession,
 				"from":       request.Info.Alias,
-				"fileCount":  len(request.Files),
+				"fileCount":  len(accepted),
 				"totalFiles": len(request.Files),
 				"files":      files,
+				"policy":     decision,
 			},
 		}
+		if storageWarning != "" {
+			notification.Data["warning"] = storageWarning
+		}
 		tool.DefaultLogger.Infof("[Notify] Sending confirm_recv notification: %v", notification)
 		tool.DefaultLogger.Debugf("Accpet by using this link: https://localhost:53317/api/self/v1/confirm-recv?sessionId=%s&confirmed=true", askSession)
+		tool.DefaultLogger.Debugf("Accept some files by adding &fileIds=<id>,<id> to the link")
 		tool.DefaultLogger.Debugf("Reject by using this link: https://localhost:53317/api/self/v1/confirm-recv?sessionId=%s&confirmed=false", askSession)
 		if err := notify.SendNotification(notification, ""); err != nil {
 			tool.DefaultLogger.Errorf("[Notify] Failed to send confirm_recv notification: %v", err)
@@ -135,6 +213,23 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 			if !result.Confirmed {
 				return nil, fmt.Errorf("rejected")
 			}
+			if len(result.AcceptedFiles) > 0 {
+				// UserConfirmRecv refuses fileIds naming none of the offered files, so chosen is not empty.
+				chosen := make(map[string]types.FileInfo, len(result.AcceptedFiles))
+				chosenIDs := make([]string, 0, len(result.AcceptedFiles))
+				for _, fileID := range result.AcceptedFiles {
+					if info, ok := accepted[fileID]; ok {
+						chosen[fileID] = info
+						chosenIDs = append(chosenIDs, fileID)
+					}
+				}
+				if len(chosen) == 0 {
+					return nil, fmt.Errorf("rejected")
+				}
+				tool.DefaultLogger.Infof("Confirm-recv accepted %d of %d files from %s", len(chosen), len(accepted), request.Info.Alias)
+				accepted = chosen
+				tool.ShrinkReceiveReservation(askSession, chosenIDs)
+			}
 		case <-confirmTimeOuttimer.C:
 			return nil, fmt.Errorf("rejected")
 		}
@@ -146,17 +241,51 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 
 	models.CreateSessionContext(askSession)
 
-	for fileID := range request.Files {
+	for fileID := range accepted {
 		response.Files[fileID] = "accepted"
 	}
 
-	models.CacheUploadSession(askSession, request.Files)
+	models.CacheUploadSession(askSession, accepted)
+	models.CacheFileDestinations(askSession, destinations)
+	models.SetSessionSender(askSession, request.Info)
+	models.ActivateInboundSession(askSession, accepted)
+	transfer.StartProgress(types.TransferInbound, askSession, request.Info.Alias, accepted)
+	admitted = true
+	share.RecordKnownDeviceReceived(request.Info, len(accepted))
 
 	return response, nil
 }
 
+// decideReceivePolicy evaluates the receivePolicy rules for request. Without a matching rule it falls back to
+// autoSave (accept), autoSaveFromFavorites with a verified favorite (accept) or ask. The decision is logged.
+func decideReceivePolicy(request *types.PrepareUploadRequest, remoteAddr string, senderVerified func() bool) types.ReceivePolicyDecision {
+	decision, ok := tool.EvaluateReceivePolicy(request, remoteAddr, senderVerified)
+	if !ok {
+		decision = types.ReceivePolicyDecision{Action: types.ReceiveActionAsk, Rule: "default"}
+		programConfig := tool.GetProgramConfigStatus()
+		switch {
+		case programConfig.AutoSave:
+			decision.Action = types.ReceiveActionAccept
+		case programConfig.AutoSaveFromFavorites && tool.IsFavorite(request.Info.Fingerprint) && senderVerified():
+			tool.DefaultLogger.Infof("Auto-accepting from favorite device: %s (fingerprint: %s)", request.Info.Alias, request.Info.Fingerprint)
+			decision.Action = types.ReceiveActionAccept
+		}
+	}
+	tool.DefaultLogger.Infof("Receive policy: %s %d files from %s (fingerprint: %s, ip: %s) by rule %s",
+		decision.Action, len(request.Files), request.Info.Alias, request.Info.Fingerprint, remoteAddr, decision.Rule)
+	if decision.Action == types.ReceiveActionAcceptSubset {
+		tool.DefaultLogger.Infof("Receive policy: accepting %d of %d files: %v", len(decision.AcceptedFiles), len(request.Files), decision.AcceptedFiles)
+	}
+	return decision
+}
+
 // DefaultOnUpload is the default callback for file upload.
-func DefaultOnUpload(sessionId, fileId, token string, data io.Reader, remoteAddr string) error {
+// The file is written to a .part file next to its destination, synced and renamed into place once size and hash match;
+// on any other failure the .part file is removed.
+// offset > 0 resumes an interrupted upload: data then starts at that byte, which must not exceed the bytes already
+// received (see UploadOffset). When resumable (the sender passed ?offset=), an interrupted body keeps the .part file
+// and returns "upload interrupted"; otherwise the file fails like any other error.
+func DefaultOnUpload(sessionId, fileId, token string, offset int64, resumable bool, data io.Reader, remoteAddr string) error {
 	if models.IsSessionCancelled(sessionId) {
 		return fmt.Errorf("session cancelled")
 	}
@@ -171,10 +300,17 @@ func DefaultOnUpload(sessionId, fileId, token string, data io.Reader, remoteAddr
 		return fmt.Errorf("file metadata not found")
 	}
 
-	uploadDir := models.DefaultUploadFolder
-	if !models.DoNotMakeSessionFolder {
-		uploadDir = filepath.Join(models.DefaultUploadFolder, sessionId)
+	if !models.BeginFileReceive(sessionId, fileId) {
+		return fmt.Errorf("upload in progress")
 	}
+	defer models.EndFileReceive(sessionId, fileId)
+
+	destination, ok := models.LookupFileDestination(sessionId, fileId)
+	if !ok {
+		destination = tool.ResolveReceiveDestination(info, types.DeviceInfo{}, remoteAddr, sessionId,
+			models.DefaultUploadFolder, models.DoNotMakeSessionFolder, func() bool { return false })
+	}
+	uploadDir := destination.Dir
 	if err := os.MkdirAll(uploadDir, 0o755); err != nil {
 		return fmt.Errorf("create upload dir failed: %w", err)
 	}
@@ -203,39 +339,80 @@ func DefaultOnUpload(sessionId, fileId, token string, data io.Reader, remoteAddr
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
 		return fmt.Errorf("create parent dir failed: %w", err)
 	}
-	if models.DoNotMakeSessionFolder {
-		targetPath = tool.NextAvailablePath(filepath.Dir(targetPath), filepath.Base(targetPath))
-	}
 
-	file, err := os.Create(targetPath)
+	partPath := tool.PartFilePath(filepath.Dir(targetPath), sessionId, fileId)
+	models.SetPartFilePath(sessionId, fileId, partPath)
+	// Any failure that cannot be resumed removes the .part file, so a corrupt file never lingers.
+	// Registered before the close below so it runs after the file is closed.
+	keepPart := false
+	defer func() {
+		if !keepPart {
+			models.RemovePartFile(sessionId, fileId, partPath)
+		}
+	}()
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o644)
 	if err != nil {
 		return fmt.Errorf("create file failed: %w", err)
 	}
 	defer func() {
-		if err := file.Close(); err != nil {
+		if err := file.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 			tool.DefaultLogger.Warnf("Failed to close file: %v", err)
 		}
 	}()
 
+	// Hash the bytes kept from earlier attempts, then drop anything after offset.
 	hasher := sha256.New()
-	writer := io.MultiWriter(file, hasher)
-
-	written, err := tool.CopyWithContext(ctx, writer, data)
-	if err != nil {
-		if ctx.Err() != nil {
-			_ = file.Close()
-			_ = os.Remove(targetPath)
-			return fmt.Errorf("upload cancelled")
+	if offset > 0 {
+		stat, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("stat partial file failed: %w", err)
+		}
+		if offset > stat.Size() || (info.Size > 0 && offset > info.Size) {
+			keepPart = true
+			return fmt.Errorf("offset mismatch")
 		}
-		return fmt.Errorf("write file failed: %w", err)
+		if _, err := io.CopyN(hasher, file, offset); err != nil {
+			return fmt.Errorf("read partial file failed: %w", err)
+		}
+		tool.DefaultLogger.Infof("Resuming upload: sessionId=%s, fileId=%s, offset=%d", sessionId, fileId, offset)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if err := file.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate partial file failed: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek partial file failed: %w", err)
 	}
 
+	writer := io.MultiWriter(file, hasher)
+
+	sender, _ := models.GetSessionSender(sessionId)
+	data = tool.LimitReader(ctx, data, types.TransferInbound, sender.Fingerprint, remoteAddr)
+	written, err := tool.CopyWithContext(ctx, writer, transfer.TrackProgress(types.TransferInbound, sessionId, fileId, offset, data))
+	written += offset
 	if ctx.Err() != nil {
-		_ = file.Close()
-		_ = os.Remove(targetPath)
 		return fmt.Errorf("upload cancelled")
 	}
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) || !resumable {
+			return fmt.Errorf("write file failed: %w", err)
+		}
+		// The sender went away; keep what we have so a retry can resume.
+		keepPart = true
+		transfer.FinishFileProgress(types.TransferInbound, sessionId, fileId, types.ProgressInterrupted)
+		tool.DefaultLogger.Warnf("Upload interrupted: sessionId=%s, fileId=%s, received=%d: %v", sessionId, fileId, written, err)
+		return fmt.Errorf("upload interrupted")
+	}
 
+	if resumable && info.Size > 0 && written < info.Size {
+		tool.DefaultLogger.Warnf("Upload ended early: sessionId=%s, fileId=%s, received=%d of %d", sessionId, fileId, written, info.Size)
+		keepPart = true
+		transfer.FinishFileProgress(types.TransferInbound, sessionId, fileId, types.ProgressInterrupted)
+		return fmt.Errorf("upload interrupted")
+	}
 	if info.Size > 0 && written != info.Size {
 		return fmt.Errorf("size mismatch")
 	}
@@ -247,11 +424,86 @@ func DefaultOnUpload(sessionId, fileId, token string, data io.Reader, remoteAddr
 		}
 	}
 
+	// Flush to disk before the rename so a crash never leaves a short file under the real name.
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("sync file failed: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close file failed: %w", err)
+	}
+	targetPath, err = finalizePartFile(partPath, targetPath, !destination.SessionUnique)
+	if err != nil {
+		return err
+	}
+	keepPart = true // renamed into place
+	models.ForgetPartFilePath(sessionId, fileId, partPath)
+
 	models.SetFileSavePath(sessionId, fileId, targetPath)
+	tool.RecordReceivedBytes(sessionId, fileId, written)
 	tool.DefaultLogger.Infof("Upload saved: sessionId=%s, fileId=%s, path=%s", sessionId, fileId, targetPath)
+	notify.RunReceiveHooks(types.ReceiveHookPayload{
+		Event:             types.ReceiveHookFileReceived,
+		SessionId:         sessionId,
+		SenderAlias:       sender.Alias,
+		SenderFingerprint: sender.Fingerprint,
+		SenderIP:          remoteAddr,
+		FileId:            fileId,
+		FileName:          info.FileName,
+		FileType:          info.FileType,
+		Size:              written,
+		SavePath:          targetPath,
+	})
 	return nil
 }
 
+// DefaultOnSessionComplete is the default callback once every file of a session is done (saved or failed),
+// right before the session is removed. It runs the session_completed receive hooks.
+func DefaultOnSessionComplete(sessionId, remoteAddr string, stats *types.SessionUploadStats, savePaths map[string]string) {
+	sender, _ := models.GetSessionSender(sessionId)
+	notify.RunReceiveHooks(types.ReceiveHookPayload{
+		Event:             types.ReceiveHookSessionCompleted,
+		SessionId:         sessionId,
+		SenderAlias:       sender.Alias,
+		SenderFingerprint: sender.Fingerprint,
+		SenderIP:          remoteAddr,
+		SavePaths:         savePaths,
+		TotalFiles:        stats.TotalFiles,
+		SuccessFiles:      stats.SuccessFiles,
+		FailedFiles:       stats.FailedFiles,
+		FailedFileIds:     stats.FailedFileIds,
+	})
+}
+
+// finalizeMu serializes picking a free name and renaming into it, so concurrent files never claim the same name.
+var finalizeMu sync.Mutex
+
+// finalizePartFile renames a completed .part file to targetPath, or to the next free name when numbered is set
+// (folders shared between sessions), and returns the final path.
+func finalizePartFile(partPath, targetPath string, numbered bool) (string, error) {
+	finalizeMu.Lock()
+	defer finalizeMu.Unlock()
+	if numbered {
+		targetPath = tool.NextAvailablePath(filepath.Dir(targetPath), filepath.Base(targetPath))
+	}
+	if err := os.Rename(partPath, targetPath); err != nil {
+		return "", fmt.Errorf("rename file failed: %w", err)
+	}
+	return targetPath, nil
+}
+
+// UploadOffset returns how many bytes of a file are kept from an interrupted upload, i.e. the offset to resume from.
+func UploadOffset(sessionId, fileId string) int64 {
+	partPath, ok := models.GetPartFilePath(sessionId, fileId)
+	if !ok {
+		return 0
+	}
+	stat, err := os.Stat(partPath)
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
+
 // DefaultOnCancel is the default callback for session cancel.
 func DefaultOnCancel(sessionId string) error {
 	tool.DefaultLogger.Infof("Received file transfer cancel request: sessionId=%s", sessionId)
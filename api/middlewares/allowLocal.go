@@ -2,12 +2,16 @@ package middlewares
 
 import (
 	"net/http"
+	"net/netip"
 
 	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
 )
 
+// OnlyAllowLocal allows loopback peers only (127.0.0.0/8, ::1 and ::ffff:127.0.0.1).
+// It checks the socket peer instead of forwarding headers, which any client could set.
 func OnlyAllowLocal(c *gin.Context) {
-	if c.ClientIP() == "127.0.0.1" || c.ClientIP() == "::1" {
+	if addr, err := netip.ParseAddr(tool.PeerIP(c)); err == nil && addr.IsLoopback() {
 		c.Next()
 	} else {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
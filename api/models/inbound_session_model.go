@@ -0,0 +1,215 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/boardcast"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// defaultInboundRetryAfter is the Retry-After hint in seconds when inboundSessions.retryAfterSeconds is unset.
+const defaultInboundRetryAfter = 30
+
+var (
+	inboundSessionsMu sync.Mutex
+	// inboundSessions holds the sessions between prepare-upload and their last file, by sessionId
+	inboundSessions = make(map[string]*types.InboundSession)
+	inboundLimits   = types.InboundSessionsConfig{RetryAfterSeconds: defaultInboundRetryAfter}
+)
+
+// SetInboundSessionLimits validates cfg and makes it the active concurrent session limits.
+func SetInboundSessionLimits(cfg types.InboundSessionsConfig) error {
+	if cfg.MaxSessions < 0 || cfg.MaxPerSender < 0 || cfg.RetryAfterSeconds < 0 {
+		return fmt.Errorf("inbound sessions: limits must not be negative")
+	}
+	if cfg.RetryAfterSeconds == 0 {
+		cfg.RetryAfterSeconds = defaultInboundRetryAfter
+	}
+	inboundSessionsMu.Lock()
+	inboundLimits = cfg
+	inboundSessionsMu.Unlock()
+	if cfg.MaxSessions > 0 || cfg.MaxPerSender > 0 {
+		tool.DefaultLogger.Infof("Inbound sessions: at most %d in total, %d per sender (0 = unlimited)", cfg.MaxSessions, cfg.MaxPerSender)
+	}
+	return nil
+}
+
+// InboundSessionRetryAfter returns the seconds a sender refused by a session limit should wait before retrying.
+func InboundSessionRetryAfter() int {
+	inboundSessionsMu.Lock()
+	defer inboundSessionsMu.Unlock()
+	return inboundLimits.RetryAfterSeconds
+}
+
+// BeginInboundSession admits a new session offering files from sender at remoteAddr in pending state.
+// It fails with "blocked by another session" when the global limit is reached and with "too many requests"
+// when the sender (same fingerprint or IP) already has its maximum of sessions.
+func BeginInboundSession(sessionId string, sender types.DeviceInfo, remoteAddr string, files map[string]types.FileInfo) error {
+	inboundSessionsMu.Lock()
+	defer inboundSessionsMu.Unlock()
+	sweepInboundSessionsLocked(time.Now())
+
+	if inboundLimits.MaxSessions > 0 && len(inboundSessions) >= inboundLimits.MaxSessions {
+		tool.DefaultLogger.Warnf("Refusing session from %s (%s): %d inbound sessions active", sender.Alias, remoteAddr, len(inboundSessions))
+		return fmt.Errorf("blocked by another session")
+	}
+	if inboundLimits.MaxPerSender > 0 {
+		count := 0
+		for _, session := range inboundSessions {
+			if session.IP == remoteAddr || (sender.Fingerprint != "" && session.Fingerprint == sender.Fingerprint) {
+				count++
+			}
+		}
+		if count >= inboundLimits.MaxPerSender {
+			tool.DefaultLogger.Warnf("Refusing session from %s (%s): sender already has %d inbound sessions", sender.Alias, remoteAddr, count)
+			return fmt.Errorf("too many requests")
+		}
+	}
+
+	now := time.Now()
+	inboundSessions[sessionId] = &types.InboundSession{
+		SessionId:    sessionId,
+		Alias:        sender.Alias,
+		Fingerprint:  sender.Fingerprint,
+		IP:           remoteAddr,
+		State:        types.InboundSessionPending,
+		Files:        inboundSessionFiles(files),
+		StartedAt:    now,
+		LastActivity: now,
+	}
+	return nil
+}
+
+// ActivateInboundSession moves an admitted session to receiving state with the accepted files and pauses scanning
+// until the session ends.
+func ActivateInboundSession(sessionId string, files map[string]types.FileInfo) {
+	inboundSessionsMu.Lock()
+	defer inboundSessionsMu.Unlock()
+	session := inboundSessions[sessionId]
+	if session == nil || session.State == types.InboundSessionReceiving {
+		return
+	}
+	session.State = types.InboundSessionReceiving
+	session.Files = inboundSessionFiles(files)
+	session.LastActivity = time.Now()
+	boardcast.PauseScan()
+}
+
+// EndInboundSession frees the slot and the unused quota reservation of a session and resumes scanning if it was
+// receiving. Ending it twice is harmless.
+func EndInboundSession(sessionId string) {
+	inboundSessionsMu.Lock()
+	defer inboundSessionsMu.Unlock()
+	endInboundSessionLocked(sessionId)
+}
+
+func endInboundSessionLocked(sessionId string) {
+	session := inboundSessions[sessionId]
+	if session == nil {
+		return
+	}
+	delete(inboundSessions, sessionId)
+	tool.ReleaseReceiveReservation(sessionId)
+	if session.State == types.InboundSessionReceiving {
+		boardcast.ResumeScan()
+	}
+}
+
+// InboundSessionOffersAny reports whether a session offers at least one of fileIds.
+func InboundSessionOffersAny(sessionId string, fileIds []string) bool {
+	inboundSessionsMu.Lock()
+	defer inboundSessionsMu.Unlock()
+	session := inboundSessions[sessionId]
+	if session == nil {
+		return false
+	}
+	return slices.ContainsFunc(session.Files, func(f types.InboundSessionFile) bool {
+		return slices.Contains(fileIds, f.FileId)
+	})
+}
+
+// ListInboundSessions returns a copy of the active inbound sessions, oldest first.
+func ListInboundSessions() []types.InboundSession {
+	inboundSessionsMu.Lock()
+	defer inboundSessionsMu.Unlock()
+	sweepInboundSessionsLocked(time.Now())
+	sessions := make([]types.InboundSession, 0, len(inboundSessions))
+	for _, session := range inboundSessions {
+		copied := *session
+		copied.Files = slices.Clone(session.Files)
+		sessions = append(sessions, copied)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartedAt.Before(sessions[j].StartedAt)
+	})
+	return sessions
+}
+
+// StartInboundSessionSweeper ends, every interval, sessions whose sender went away without finishing or
+// cancelling, so they stop holding a slot and pausing the scan. interval <= 0 disables the sweeper.
+func StartInboundSessionSweeper(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		inboundSessionsMu.Lock()
+		sweepInboundSessionsLocked(time.Now())
+		inboundSessionsMu.Unlock()
+	}
+}
+
+// sweepInboundSessionsLocked ends sessions idle for longer than the session TTL, unless a file is still being
+// received. inboundSessionsMu must be held.
+func sweepInboundSessionsLocked(now time.Time) {
+	for sessionId, session := range inboundSessions {
+		if now.Sub(session.LastActivity) < tool.DefaultTTL {
+			continue
+		}
+		if slices.ContainsFunc(session.Files, func(f types.InboundSessionFile) bool { return f.State == types.InboundFileReceiving }) {
+			continue
+		}
+		tool.DefaultLogger.Infof("Inbound session %s from %s expired", sessionId, session.Alias)
+		endInboundSessionLocked(sessionId)
+	}
+}
+
+// setInboundFileState sets the state of a file of a session to state, only if it is currently from ("" = any).
+func setInboundFileState(sessionId, fileId, from, state string) {
+	inboundSessionsMu.Lock()
+	defer inboundSessionsMu.Unlock()
+	session := inboundSessions[sessionId]
+	if session == nil {
+		return
+	}
+	session.LastActivity = time.Now()
+	for i := range session.Files {
+		if session.Files[i].FileId == fileId && (from == "" || session.Files[i].State == from) {
+			session.Files[i].State = state
+			return
+		}
+	}
+}
+
+func inboundSessionFiles(files map[string]types.FileInfo) []types.InboundSessionFile {
+	list := make([]types.InboundSessionFile, 0, len(files))
+	for fileId, info := range files {
+		list = append(list, types.InboundSessionFile{
+			FileId:   fileId,
+			FileName: info.FileName,
+			FileType: info.FileType,
+			Size:     info.Size,
+			State:    types.InboundFilePending,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].FileId < list[j].FileId
+	})
+	return list
+}
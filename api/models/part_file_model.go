@@ -0,0 +1,72 @@
+package models
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// partFileMinAge protects .part files that were created moments ago from the janitor.
+const partFileMinAge = time.Minute
+
+// StartPartFileJanitor removes orphaned .part files under the upload folder every interval, e.g. left behind
+// by a crash or an expired session. The first sweep runs immediately. interval <= 0 disables the janitor.
+func StartPartFileJanitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	tool.DefaultLogger.Infof("Starting partial file janitor (every %v)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if removed := CleanupOrphanedPartFiles(); removed > 0 {
+			tool.DefaultLogger.Infof("Partial file janitor: removed %d orphaned files", removed)
+		}
+		<-ticker.C
+	}
+}
+
+// CleanupOrphanedPartFiles deletes .part files under DefaultUploadFolder and the receive routing folders that
+// no live upload session owns and returns how many were removed.
+func CleanupOrphanedPartFiles() int {
+	removed := 0
+	for _, root := range append([]string{DefaultUploadFolder}, tool.ListReceiveRoots()...) {
+		removed += cleanupOrphanedPartFilesIn(root)
+	}
+	return removed
+}
+
+func cleanupOrphanedPartFilesIn(root string) int {
+	removed := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !tool.IsPartFileName(d.Name()) {
+			return nil
+		}
+		if IsActivePartFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || time.Since(info.ModTime()) < partFileMinAge {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			tool.DefaultLogger.Warnf("Partial file janitor: failed to remove %s: %v", path, err)
+			return nil
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		tool.DefaultLogger.Warnf("Partial file janitor: failed to walk %s: %v", root, err)
+	}
+	return removed
+}
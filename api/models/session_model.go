@@ -3,10 +3,13 @@ package models
 import (
 	"context"
 	"maps"
+	"os"
 	"sync"
+	"time"
 
 	ttlworker "github.com/FloatTech/ttl"
 	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
 	"github.com/moyoez/localsend-go/types"
 )
 
@@ -25,8 +28,43 @@ var (
 	uploadStats = ttlworker.NewCache[string, *types.SessionUploadStats](tool.DefaultTTL)
 	// fileSavePaths stores actual save path per (sessionId, fileId) for notifications
 	fileSavePaths = ttlworker.NewCache[string, map[string]string](tool.DefaultTTL)
+	// fileDestinations stores the resolved destination per (sessionId, fileId), decided at prepare-upload
+	fileDestinations = ttlworker.NewCache[string, map[string]types.ReceiveDestination](tool.DefaultTTL)
+	// sessionSenders stores the device info of the sender of each session
+	sessionSenders = ttlworker.NewCache[string, types.DeviceInfo](tool.DefaultTTL)
+	// partFiles stores the .part path per (sessionId, fileId); the files are deleted when the session is removed or expires.
+	// The maps are replaced, never modified, so onPartFilesDelete can walk them without uploadSessionMu.
+	partFiles = ttlworker.NewCacheOn(tool.DefaultTTL, [4]func(string, map[string]string){
+		nil, nil, onPartFilesDelete, nil,
+	})
+	// activePartFiles holds the paths in partFiles so the janitor can tell them from orphans without touching the TTL
+	activePartFiles sync.Map
+	// receivingFiles holds "sessionId/fileId" of files with a running upload request, with the channel stopping
+	// the refresh of their partFiles entry
+	receivingFiles sync.Map
+	// resumeTimers holds the *resumeTimer of interrupted files waiting to be resumed, by "sessionId/fileId"
+	resumeTimers sync.Map
 )
 
+type resumeTimer struct {
+	timer *time.Timer
+}
+
+// onPartFilesDelete removes the unfinished files of a cancelled, completed or expired session. Files still being
+// received are left to their upload request, which removes or renames them when it ends.
+func onPartFilesDelete(sessionId string, paths map[string]string) {
+	for fileId, path := range paths {
+		if _, receiving := receivingFiles.Load(sessionId + "/" + fileId); receiving {
+			tool.DefaultLogger.Debugf("Keeping partial file %s (sessionId=%s, fileId=%s): still being received", path, sessionId, fileId)
+			continue
+		}
+		activePartFiles.Delete(path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			tool.DefaultLogger.Warnf("Failed to remove partial file %s (sessionId=%s, fileId=%s): %v", path, sessionId, fileId, err)
+		}
+	}
+}
+
 func CacheUploadSession(sessionId string, files map[string]types.FileInfo) {
 	uploadSessionMu.Lock()
 	defer uploadSessionMu.Unlock()
@@ -95,9 +133,13 @@ func MarkFileUploadedAndCheckComplete(sessionId, fileId string, success bool) (r
 
 	if success {
 		sessionStats.SuccessFiles++
+		setInboundFileState(sessionId, fileId, "", types.InboundFileDone)
+		transfer.FinishFileProgress(types.TransferInbound, sessionId, fileId, types.ProgressDone)
 	} else {
 		sessionStats.FailedFiles++
 		sessionStats.FailedFileIds = append(sessionStats.FailedFileIds, fileId)
+		setInboundFileState(sessionId, fileId, "", types.InboundFileFailed)
+		transfer.FinishFileProgress(types.TransferInbound, sessionId, fileId, types.ProgressFailed)
 	}
 	uploadStats.Set(sessionId, sessionStats)
 
@@ -108,6 +150,7 @@ func MarkFileUploadedAndCheckComplete(sessionId, fileId string, success bool) (r
 
 	if isLast {
 		uploadSessions.Delete(sessionId)
+		EndInboundSession(sessionId)
 		// Keep stats for the notification, will be cleaned up later
 	} else {
 		uploadSessions.Set(sessionId, files)
@@ -160,6 +203,150 @@ func GetSessionSavePaths(sessionId string) map[string]string {
 	return out
 }
 
+// CacheFileDestinations stores where each accepted file of the session is saved.
+func CacheFileDestinations(sessionId string, destinations map[string]types.ReceiveDestination) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	copied := make(map[string]types.ReceiveDestination, len(destinations))
+	maps.Copy(copied, destinations)
+	fileDestinations.Set(sessionId, copied)
+}
+
+// LookupFileDestination returns the destination resolved for a file at prepare-upload.
+func LookupFileDestination(sessionId, fileId string) (types.ReceiveDestination, bool) {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	m := fileDestinations.Get(sessionId)
+	if m == nil {
+		return types.ReceiveDestination{}, false
+	}
+	destination, ok := m[fileId]
+	return destination, ok
+}
+
+// SetSessionSender records who sends the session.
+func SetSessionSender(sessionId string, sender types.DeviceInfo) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	sessionSenders.Set(sessionId, sender)
+}
+
+// GetSessionSender returns the sender recorded for the session.
+func GetSessionSender(sessionId string) (types.DeviceInfo, bool) {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	sender := sessionSenders.Get(sessionId)
+	return sender, sender.Fingerprint != "" || sender.Alias != ""
+}
+
+// SetPartFilePath records the .part file a file of the session is written to.
+func SetPartFilePath(sessionId, fileId, partPath string) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	m := maps.Clone(partFiles.Get(sessionId))
+	if m == nil {
+		m = make(map[string]string)
+	}
+	m[fileId] = partPath
+	partFiles.Set(sessionId, m)
+	activePartFiles.Store(partPath, struct{}{})
+}
+
+// GetPartFilePath returns the .part file of a file, if it has one.
+func GetPartFilePath(sessionId, fileId string) (string, bool) {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	m := partFiles.Get(sessionId)
+	if m == nil {
+		return "", false
+	}
+	path, ok := m[fileId]
+	return path, ok
+}
+
+// ForgetPartFilePath drops the .part record of a file without deleting it (after it was renamed into place).
+// partPath is passed in because the record is gone when the session ended while the file was received.
+func ForgetPartFilePath(sessionId, fileId, partPath string) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	activePartFiles.Delete(partPath)
+	if m := partFiles.Get(sessionId); m != nil {
+		if _, ok := m[fileId]; ok {
+			m = maps.Clone(m)
+			delete(m, fileId)
+			partFiles.Set(sessionId, m)
+		}
+	}
+}
+
+// RemovePartFile deletes the .part file of a failed upload and drops its record.
+func RemovePartFile(sessionId, fileId, partPath string) {
+	if err := os.Remove(partPath); err != nil && !os.IsNotExist(err) {
+		tool.DefaultLogger.Warnf("Failed to remove partial file %s: %v", partPath, err)
+	}
+	ForgetPartFilePath(sessionId, fileId, partPath)
+}
+
+// IsActivePartFile reports whether partPath belongs to an upload session that is still alive.
+func IsActivePartFile(partPath string) bool {
+	_, ok := activePartFiles.Load(partPath)
+	return ok
+}
+
+// BeginFileReceive marks a file as being received; false if another request is already writing it.
+// While marked, the .part record of the session is kept from expiring, however long the upload takes.
+func BeginFileReceive(sessionId, fileId string) bool {
+	stop := make(chan struct{})
+	_, busy := receivingFiles.LoadOrStore(sessionId+"/"+fileId, stop)
+	if !busy {
+		if t, ok := resumeTimers.LoadAndDelete(sessionId + "/" + fileId); ok {
+			t.(*resumeTimer).timer.Stop()
+		}
+		setInboundFileState(sessionId, fileId, types.InboundFilePending, types.InboundFileReceiving)
+		go refreshPartFiles(sessionId, stop)
+	}
+	return !busy
+}
+
+// EndFileReceive releases the mark set by BeginFileReceive.
+func EndFileReceive(sessionId, fileId string) {
+	if stop, ok := receivingFiles.LoadAndDelete(sessionId + "/" + fileId); ok {
+		close(stop.(chan struct{}))
+	}
+	setInboundFileState(sessionId, fileId, types.InboundFileReceiving, types.InboundFilePending)
+}
+
+// ExpireUnresumedFile calls onExpire after d unless an upload of the file starts first (see BeginFileReceive).
+// A later call for the same file replaces the pending one.
+func ExpireUnresumedFile(sessionId, fileId string, d time.Duration, onExpire func()) {
+	key := sessionId + "/" + fileId
+	t := &resumeTimer{}
+	t.timer = time.AfterFunc(d, func() {
+		if resumeTimers.CompareAndDelete(key, t) {
+			onExpire()
+		}
+	})
+	if old, loaded := resumeTimers.Swap(key, t); loaded {
+		old.(*resumeTimer).timer.Stop()
+	}
+}
+
+// refreshPartFiles resets the TTL of the .part record of a session well before it expires, until stop is closed.
+func refreshPartFiles(sessionId string, stop <-chan struct{}) {
+	ticker := time.NewTicker(tool.DefaultTTL / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			uploadSessionMu.RLock()
+			partFiles.Get(sessionId)
+			uploadSessionMu.RUnlock()
+		}
+	}
+}
+
 // CleanupSessionStats removes the upload statistics for a session
 func CleanupSessionStats(sessionId string) {
 	uploadSessionMu.Lock()
@@ -174,6 +361,11 @@ func RemoveUploadSession(sessionId string) {
 	uploadValidated.Delete(sessionId)
 	confirmRecvChans.Delete(sessionId)
 	fileSavePaths.Delete(sessionId)
+	fileDestinations.Delete(sessionId)
+	sessionSenders.Delete(sessionId)
+	partFiles.Delete(sessionId)
+	EndInboundSession(sessionId)
+	transfer.EndProgress(types.TransferInbound, sessionId)
 	// Cancel the session context to interrupt ongoing uploads
 	if sessCtx := sessionContexts.Get(sessionId); sessCtx != nil {
 		sessCtx.Cancel()
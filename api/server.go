@@ -112,16 +112,32 @@ func (s *Server) setupRoutes() *gin.Engine {
 	}
 	self := engine.Group("/api/self/v1", middlewares.OnlyAllowLocal)
 	{
-		self.GET("/get-network-info", controllers.UserGetNetworkInfo)           // Get local network info with IP and segment number
-		self.GET("/scan-current", controllers.UserScanCurrent)                  // Get current scanned devices
-		self.GET("/scan-now", controllers.UserScanNow)                          // Trigger immediate scan based on current config
-		self.POST("/prepare-upload", controllers.UserPrepareUpload)             // Prepare upload endpoint
-		self.POST("/upload", controllers.UserUpload)                            // Actual upload endpoint
-		self.POST("/upload-batch", controllers.UserUploadBatch)                 // Batch upload endpoint (supports file:/// protocol)
-		self.GET("/confirm-recv", controllers.UserConfirmRecv)                  // Confirm recv endpoint
-		self.GET("/text-received-dismiss", controllers.UserTextReceivedDismiss) // Text received modal dismiss
-		self.GET("/confirm-download", controllers.UserConfirmDownload)          // Confirm download endpoint
-		self.POST("/cancel", controllers.UserCancelUpload)                      // Cancel upload endpoint (sender side)
+		self.GET("/get-network-info", controllers.UserGetNetworkInfo)            // Get local network info with IP and segment number
+		self.GET("/scan-current", controllers.UserScanCurrent)                   // Get current scanned devices
+		self.GET("/scan-now", controllers.UserScanNow)                           // Trigger immediate scan based on current config
+		self.GET("/scan-targets", controllers.UserScanTargetsGet)                // List configured HTTP scan targets
+		self.PUT("/scan-targets", controllers.UserScanTargetsSet)                // Replace configured HTTP scan targets (CIDR, range or IP)
+		self.GET("/scan-progress", controllers.UserScanProgress)                 // HTTP scan sweep progress
+		self.GET("/scan-mode", controllers.UserScanModeGet)                      // Current scan mode
+		self.PUT("/scan-mode", controllers.UserScanModeSet)                      // Switch scan mode at runtime (udp|http|mixed|mdns|off)
+		self.GET("/register-stats", controllers.UserRegisterStats)               // Register answer counters (sent, failed, deduped, throttled)
+		self.GET("/diagnostics/discovery", controllers.UserDiscoveryDiagnostics) // Per-interface discovery counters, scan state and loopback self-test
+		self.GET("/static-peers", controllers.UserStaticPeersList)               // List static peers with online status
+		self.POST("/static-peers", controllers.UserStaticPeersAdd)               // Add a static peer (host, port, fingerprint) and poll it
+		self.DELETE("/static-peers", controllers.UserStaticPeersDelete)          // Remove a static peer (?host=&port=)
+		self.GET("/known-devices", controllers.UserKnownDevicesList)             // Persisted known devices with online status
+		self.DELETE("/known-devices", controllers.UserKnownDevicesDelete)        // Forget a known device (?fingerprint=)
+		self.GET("/inbound-sessions", controllers.UserInboundSessionsList)       // Sessions being received: sender, files and state
+		self.GET("/transfer-progress", controllers.UserTransferProgress)         // Bytes, throughput and ETA of running transfers
+		self.GET("/bandwidth", controllers.UserBandwidthGet)                     // Active send/receive bandwidth limits
+		self.PUT("/bandwidth", controllers.UserBandwidthSet)                     // Change bandwidth limits, also for running transfers
+		self.POST("/prepare-upload", controllers.UserPrepareUpload)              // Prepare upload endpoint
+		self.POST("/upload", controllers.UserUpload)                             // Actual upload endpoint
+		self.POST("/upload-batch", controllers.UserUploadBatch)                  // Batch upload endpoint (supports file:/// protocol)
+		self.GET("/confirm-recv", controllers.UserConfirmRecv)                   // Confirm recv endpoint
+		self.GET("/text-received-dismiss", controllers.UserTextReceivedDismiss)  // Text received modal dismiss
+		self.GET("/confirm-download", controllers.UserConfirmDownload)           // Confirm download endpoint
+		self.POST("/cancel", controllers.UserCancelUpload)                       // Cancel upload endpoint (sender side)
 		self.GET("/get-image", controllers.UserGetImage)
 		self.GET("/favorites", controllers.UserFavoritesList)                     // List favorite devices
 		self.POST("/favorites", controllers.UserFavoritesAdd)                     // Add a favorite device
@@ -131,6 +147,7 @@ func (s *Server) setupRoutes() *gin.Engine {
 		self.DELETE("/close-share-session", controllers.UserCloseShareSession)    // Close share session
 		self.GET("/create-qr-code", controllers.GenerateQRCode)                   // QR code PNG (same params as api.qrserver.com)
 		self.GET("/get-user-screenshot", controllers.GetUserScreenShot)           // made screenshot in frontend.
+		self.GET("/events", controllers.UserEvents)                               // Server-Sent Events stream of notifications
 	}
 
 	// Serve Next.js static export for download page at root (when Download enabled and web/out exists)
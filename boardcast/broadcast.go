@@ -0,0 +1,173 @@
+package boardcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+	"golang.org/x/net/ipv4"
+)
+
+// Broadcast discovery sends the multicast announcement to 255.255.255.255 and to the directed broadcast
+// address of every enabled interface, for routers and access points that drop multicast.
+
+var (
+	// broadcastInterfaces selects the interfaces that run broadcast discovery (disabled by default).
+	broadcastInterfaces interfaceSelector
+
+	broadcastListenerOnce sync.Once
+)
+
+// SetBroadcastInterfaces enables broadcast discovery per interface.
+// "" disables it, "*" enables it on every usable interface, otherwise a comma-separated list of names (e.g. "eth0,wlan0").
+func SetBroadcastInterfaces(spec string) {
+	broadcastInterfaces.set(spec)
+}
+
+// IsBroadcastEnabled reports whether broadcast discovery is enabled on at least one interface.
+func IsBroadcastEnabled() bool {
+	return broadcastInterfaces.any()
+}
+
+// startBroadcastListener starts ListenBroadcastUsingUDP once; it does not depend on the scan mode.
+func startBroadcastListener(self *types.VersionMessage) {
+	broadcastListenerOnce.Do(func() {
+		go ListenBroadcastUsingUDP(self)
+	})
+}
+
+// ListenBroadcastUsingUDP receives broadcast announcements on the discovery port and handles them like
+// multicast ones. Multicast packets are left to the multicast listeners, and copies of one announcement
+// received on several sockets are dropped by handleMulticastPacket. It blocks.
+func ListenBroadcastUsingUDP(self *types.VersionMessage) {
+	lc := net.ListenConfig{Control: broadcastSocketControl}
+	c, err := lc.ListenPacket(context.Background(), "udp4", fmt.Sprintf("0.0.0.0:%d", multcastPort))
+	recordJoin("*", discoveryChannelBroadcast, err)
+	if err != nil {
+		tool.DefaultLogger.Errorf("Broadcast discovery disabled: failed to listen on UDP port %d: %v", multcastPort, err)
+		return
+	}
+	defer func() {
+		recordLeave("*", discoveryChannelBroadcast)
+		if err := c.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close broadcast UDP connection: %v", err)
+		}
+	}()
+	pc := ipv4.NewPacketConn(c)
+	// Interface and destination tell broadcast from multicast and enabled from disabled interfaces; unsupported on Windows.
+	if err := pc.SetControlMessage(ipv4.FlagInterface|ipv4.FlagDst, true); err != nil {
+		tool.DefaultLogger.Debugf("Broadcast: control messages unavailable: %v", err)
+	}
+	tool.DefaultLogger.Infof("Listening for broadcast announcements on UDP port %d", multcastPort)
+
+	buf := make([]byte, 1024*8)
+	for {
+		n, cm, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			tool.DefaultLogger.Errorf("Error reading broadcast UDP: %v", err)
+			continue
+		}
+		interfaceName := "broadcast"
+		if cm != nil {
+			if cm.Dst != nil && cm.Dst.IsMulticast() {
+				continue
+			}
+			if iface, err := net.InterfaceByIndex(cm.IfIndex); err == nil {
+				if !broadcastInterfaces.enabled(iface.Name) {
+					continue
+				}
+				interfaceName = iface.Name
+			}
+		}
+		recordReceived(interfaceName, discoveryChannelBroadcast, handleMulticastPacket(buf[:n], from, interfaceName, discoveryChannelBroadcast, self))
+	}
+}
+
+// sendBroadcastPayload writes payload to the limited broadcast address and to the directed broadcast
+// address of every enabled interface.
+func sendBroadcastPayload(payload []byte) error {
+	lc := net.ListenConfig{Control: broadcastSocketControl}
+	c, err := lc.ListenPacket(context.Background(), "udp4", "0.0.0.0:0")
+	if err != nil {
+		return fmt.Errorf("failed to open broadcast UDP socket: %v", err)
+	}
+	defer func() {
+		if err := c.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close broadcast UDP connection: %v", err)
+		}
+	}()
+	var errs []error
+	for _, ip := range broadcastTargets() {
+		target := &net.UDPAddr{IP: ip, Port: multcastPort}
+		_, err := c.WriteTo(payload, target)
+		recordSent("*", discoveryChannelBroadcast, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.String(), err))
+			continue
+		}
+		tool.DefaultLogger.Debugf("Sent UDP broadcast message to %s", target.String())
+	}
+	return errors.Join(errs...)
+}
+
+// broadcastTargets returns 255.255.255.255 followed by the directed broadcast addresses of the enabled interfaces.
+func broadcastTargets() []net.IP {
+	targets := []net.IP{net.IPv4bcast}
+	seen := map[string]struct{}{net.IPv4bcast.String(): {}}
+	interfaces, err := getNetworkInterfaces()
+	if err != nil {
+		return targets
+	}
+	for _, iface := range interfaces {
+		if iface == nil || iface.Flags&net.FlagBroadcast == 0 || !broadcastInterfaces.enabled(iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := directedBroadcast(ipNet)
+			if ip == nil {
+				continue
+			}
+			if _, ok := seen[ip.String()]; ok {
+				continue
+			}
+			seen[ip.String()] = struct{}{}
+			targets = append(targets, ip)
+		}
+	}
+	return targets
+}
+
+// directedBroadcast returns the broadcast address of an IPv4 network, or nil for IPv6, loopback and /31 or /32.
+func directedBroadcast(ipNet *net.IPNet) net.IP {
+	ip4 := ipNet.IP.To4()
+	if ip4 == nil || ip4.IsLoopback() {
+		return nil
+	}
+	mask := ipNet.Mask
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+	if ones, bits := mask.Size(); bits != 32 || ones >= 31 {
+		return nil
+	}
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range broadcast {
+		broadcast[i] = ip4[i] | ^mask[i]
+	}
+	return broadcast
+}
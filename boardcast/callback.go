@@ -12,31 +12,42 @@ import (
 
 // CallbackMulticastMessageUsingTCP calls the /register callback using HTTP/TCP.
 func CallbackMulticastMessageUsingTCP(targetAddr *net.UDPAddr, self *types.CallbackVersionMessageHTTP, remote *types.VersionMessage) error {
+	_, err := callbackRegister(targetAddr, self, remote)
+	return err
+}
+
+// callbackRegister is CallbackMulticastMessageUsingTCP and also reports whether the answer had to fall back to UDP.
+func callbackRegister(targetAddr *net.UDPAddr, self *types.CallbackVersionMessageHTTP, remote *types.VersionMessage) (bool, error) {
 	if err := validateCallbackParams(targetAddr, self, remote); err != nil {
-		return err
+		return false, err
 	}
 	// Only respond to callbacks if announce=true.
 	if !remote.Announce {
-		return nil
+		return false, nil
 	}
 
 	// Call the /register callback to send the device information to the remote device.
 	url, buildErr := tool.BuildRegisterURL(targetAddr, remote)
 	if buildErr != nil {
-		return buildErr
+		return false, buildErr
 	}
 	payload, err := sonic.Marshal(self)
 	if err != nil {
-		return err
+		return false, err
 	}
 	// Try sending register request via HTTP
-	if sendErr := sendRegisterRequest(url, tool.BytesToString(payload)); sendErr != nil {
+	if sendErr := sendRegisterRequest(url, tool.BytesToString(payload), remote.Fingerprint); sendErr != nil {
 		// debug what msg sent
 		tool.DefaultLogger.Warnf("Failed to send register request via HTTP: %v. Falling back to UDP multicast.", sendErr)
 		// Fallback: Respond using UDP multicast (announce=false)
 		response := *self
 		//	https://github.com/localsend/protocol/blob/main/README.md#31-multicast-udp-default
-		if udpErr := CallbackMulticastMessageUsingUDP(&types.VersionMessage{
+		callbackUDP := CallbackMulticastMessageUsingUDP
+		if targetAddr.IP.To4() == nil {
+			// The peer announced over IPv6, answer on the IPv6 group.
+			callbackUDP = CallbackMulticastMessageUsingUDPv6
+		}
+		if udpErr := callbackUDP(&types.VersionMessage{
 			Alias:       response.Alias,
 			Version:     response.Version,
 			DeviceModel: response.DeviceModel,
@@ -46,10 +57,11 @@ func CallbackMulticastMessageUsingTCP(targetAddr *net.UDPAddr, self *types.Callb
 			Protocol:    response.Protocol,
 			Announce:    false,
 		}); udpErr != nil {
-			return fmt.Errorf("both HTTP and UDP multicast fallback failed: %v; original: %v", udpErr, sendErr)
+			return true, fmt.Errorf("both HTTP and UDP multicast fallback failed: %v; original: %v", udpErr, sendErr)
 		}
+		return true, nil
 	}
-	return nil
+	return false, nil
 }
 
 // validateCallbackParams validates the callback parameters (internal use).
@@ -15,23 +15,37 @@ import (
 // refer to https://github.com/localsend/protocol/blob/main/README.md#1-defaults
 const (
 	defaultMultcastAddress = "224.0.0.167"
-	defaultMultcastPort    = 53317 // UDP & HTTP
+	// defaultMultcastAddressV6 is the link-local IPv6 group used for discovery next to the IPv4 group.
+	defaultMultcastAddressV6 = "ff02::167"
+	defaultMultcastPort      = 53317 // UDP & HTTP
 	// scanNowHTTPConcurrency is the concurrency cap for scan-now (no rate limit; high concurrency for speed)
 	scanNowHTTPConcurrency = 256
 	// autoScanConcurrencyLimit limits concurrent HTTP scan goroutines for periodic auto scan (16~32)
 	autoScanConcurrencyLimit = 24
 	// autoScanICMPRatePPS is the ICMP probe rate limit (packets per second) for auto scan; /24 ~ 6~12s
 	autoScanICMPRatePPS = 30
-	// icmpProbeTimeout is the timeout for ICMP echo probe (host reachability before HTTP register)
-	icmpProbeTimeout = 200 * time.Millisecond
+	// autoScanChunkSize caps the addresses probed per auto scan tick; larger target lists continue on the next tick
+	autoScanChunkSize = 1024
+	// scanNowMaxTargets caps the addresses probed by one scan-now call
+	scanNowMaxTargets = 4096
+	// autoScanChunkBudget is the time a chunk should take; the probe rate is raised to fit (see adaptiveScanRate)
+	autoScanChunkBudget = 25 * time.Second
+	// autoScanMaxRatePPS is the upper bound of the adaptive probe rate
+	autoScanMaxRatePPS = 120
+	// hostProbeTimeout is the timeout for the host probe (ICMP echo or TCP connect, reachability before HTTP register)
+	hostProbeTimeout = 200 * time.Millisecond
 )
 
 var (
 	multcastAddress       = defaultMultcastAddress
+	multcastAddressV6     = defaultMultcastAddressV6
 	multcastPort          = defaultMultcastPort
 	referNetworkInterface string // the specified network interface name
 	listenAllInterfaces   = true // whether to listen on all network interfaces
 
+	// ipv6Interfaces selects the interfaces that run IPv6 multicast discovery (disabled by default).
+	ipv6Interfaces interfaceSelector
+
 	// networkIPsCache caches generated network IPs to avoid repeated generation
 	networkIPsCacheMu  sync.RWMutex
 	networkIPsCache    []string
@@ -44,8 +58,10 @@ var (
 	// autoScanControl controls the auto scan loops
 	autoScanControlMu   sync.Mutex
 	autoScanRestartCh   chan restartAction // channel to signal restart
-	autoScanHTTPRunning bool
-	autoScanUDPRunning  bool
+	autoScanStopCh      chan struct{}      // closed by StopAutoScan; loops capture it when they start
+	autoScanHTTPRunning int                // running loops per kind; a stopped loop may exit after its replacement started
+	autoScanUDPRunning  int
+	autoScanMDNSRunning int
 
 	// scanPauseCount is an atomic reference counter for pausing scans during file transfers.
 	// When > 0, scan loops skip their ticks without resetting timers.
@@ -89,6 +105,70 @@ func SetMultcastPort(port int) {
 	}
 }
 
+// SetMultcastAddressV6 overrides the default IPv6 multicast group.
+func SetMultcastAddressV6(address string) {
+	if address != "" {
+		multcastAddressV6 = address
+	}
+}
+
+// SetIPv6MulticastInterfaces enables IPv6 multicast discovery per interface.
+// "" disables it, "*" enables it on every usable interface, otherwise a comma-separated list of names (e.g. "eth0,wlan0").
+func SetIPv6MulticastInterfaces(spec string) {
+	ipv6Interfaces.set(spec)
+}
+
+// IsIPv6MulticastEnabled reports whether IPv6 multicast discovery is enabled on at least one interface.
+func IsIPv6MulticastEnabled() bool {
+	return ipv6Interfaces.any()
+}
+
+// interfaceSelector decides per interface whether an optional discovery channel is enabled.
+type interfaceSelector struct {
+	mu    sync.RWMutex
+	all   bool
+	names map[string]struct{}
+}
+
+// set parses "" (off), "*" (all) or a comma-separated list of interface names.
+func (s *interfaceSelector) set(spec string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.all = false
+	s.names = nil
+	spec = strings.TrimSpace(spec)
+	if spec == "*" {
+		s.all = true
+		return
+	}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if s.names == nil {
+			s.names = make(map[string]struct{})
+		}
+		s.names[name] = struct{}{}
+	}
+}
+
+func (s *interfaceSelector) enabled(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.all {
+		return true
+	}
+	_, ok := s.names[name]
+	return ok
+}
+
+func (s *interfaceSelector) any() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.all || len(s.names) > 0
+}
+
 // SetReferNetworkInterface sets the network interface to use for multicast.
 // If interfaceName is empty, it will use the system default interface.
 // If interfaceName is "*", it will listen on all available interfaces.
@@ -142,21 +222,51 @@ func getNetworkInterfaces() ([]*net.Interface, error) {
 	return []*net.Interface{nil}, nil
 }
 
-// getCachedNetworkIPs returns cached network IPs or generates new ones if cache is invalid.
+// getNetworkInterfacesV6 returns the interfaces that have IPv6 multicast discovery enabled.
+// It honors useReferNetworkInterface in the same way as getNetworkInterfaces.
+func getNetworkInterfacesV6() ([]*net.Interface, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %v", err)
+	}
+	var validInterfaces []*net.Interface
+	for i := range interfaces {
+		iface := &interfaces[i]
+		if !listenAllInterfaces && iface.Name != referNetworkInterface {
+			continue
+		}
+		if !ipv6Interfaces.enabled(iface.Name) || tool.RejectUnsupportNetworkInterfaceV6(iface) {
+			continue
+		}
+		validInterfaces = append(validInterfaces, iface)
+	}
+	if len(validInterfaces) == 0 {
+		return nil, fmt.Errorf("no valid IPv6 network interfaces found")
+	}
+	return validInterfaces, nil
+}
+
+// getCachedNetworkIPs returns cached network IPs (or generates new ones if cache is invalid) and the cache key.
 // It strictly follows useReferNetworkInterface: when a specific interface is set, only IPs from that interface's network(s) are returned.
-// Cache key includes interface config to invalidate on config change.
-func getCachedNetworkIPs() ([]string, error) {
+// Configured scan targets (scanTargets in config) are appended in full, without the 254-host cap of derived networks.
+// Cache key includes interface config and scan targets to invalidate on config change.
+func getCachedNetworkIPs() ([]string, string, error) {
 	var addrs []net.Addr
+	scanTargets := tool.ListScanTargets()
 	interfaces, err := getNetworkInterfaces()
 	if err != nil {
-		return nil, err
+		if len(scanTargets) == 0 {
+			return nil, "", err
+		}
+		tool.DefaultLogger.Debugf("getCachedNetworkIPs: %v, using configured scan targets only", err)
+		interfaces = nil
 	}
 	for _, iface := range interfaces {
 		if iface == nil {
 			// system default: fall back to InterfaceAddrs
 			allAddrs, err := net.InterfaceAddrs()
 			if err != nil {
-				return nil, err
+				return nil, "", err
 			}
 			addrs = append(addrs, allAddrs...)
 			continue
@@ -174,6 +284,8 @@ func getCachedNetworkIPs() ([]string, error) {
 	keyBuilder.WriteString(fmt.Sprint(listenAllInterfaces))
 	keyBuilder.WriteString(";rif:")
 	keyBuilder.WriteString(referNetworkInterface)
+	keyBuilder.WriteString(";st:")
+	keyBuilder.WriteString(strings.Join(scanTargets, ","))
 	keyBuilder.WriteString(";")
 	for _, addr := range addrs {
 		ipnet, ok := addr.(*net.IPNet)
@@ -192,7 +304,7 @@ func getCachedNetworkIPs() ([]string, error) {
 		result := make([]string, len(networkIPsCache))
 		copy(result, networkIPsCache)
 		networkIPsCacheMu.RUnlock()
-		return result, nil
+		return result, currentKey, nil
 	}
 	networkIPsCacheMu.RUnlock()
 
@@ -206,6 +318,21 @@ func getCachedNetworkIPs() ([]string, error) {
 		networkIPs := tool.GenerateNetworkIPs(ipnet)
 		targets = append(targets, networkIPs...)
 	}
+	if len(scanTargets) > 0 {
+		configured, err := tool.ExpandScanTargets(scanTargets)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid scan targets: %v", err)
+		}
+		seen := make(map[string]struct{}, len(targets))
+		for _, ip := range targets {
+			seen[ip] = struct{}{}
+		}
+		for _, ip := range configured {
+			if _, ok := seen[ip]; !ok {
+				targets = append(targets, ip)
+			}
+		}
+	}
 
 	// Update cache
 	networkIPsCacheMu.Lock()
@@ -216,15 +343,27 @@ func getCachedNetworkIPs() ([]string, error) {
 	// Return a copy
 	result := make([]string, len(targets))
 	copy(result, targets)
-	return result, nil
+	return result, currentKey, nil
+}
+
+// RebindHTTPClients (re)initializes the HTTP clients with the preferred outgoing bind addresses.
+func RebindHTTPClients() {
+	bindAddrs, err := GetPreferredOutgoingBindAddr()
+	if err != nil {
+		tool.DefaultLogger.Warnf("GetPreferredOutgoingBindAddr: %v, HTTP clients will use default interface", err)
+		tool.InitHTTPClients(nil)
+		return
+	}
+	tool.InitHTTPClients(bindAddrs)
 }
 
-// GetPreferredOutgoingBindAddr returns the local address to bind outgoing HTTP connections to.
-// When useReferNetworkInterface specifies a concrete interface (not "*"), returns the first
-// valid IPv4 address on that interface so HTTP requests use that interface.
+// GetPreferredOutgoingBindAddr returns the local addresses to bind outgoing HTTP connections to.
+// When useReferNetworkInterface specifies a concrete interface (not "*"), returns the first valid IPv4,
+// IPv6 and IPv6 link-local (zoned) address on that interface so HTTP requests to peers of either family use
+// that interface; a family the interface has no address for is dialed unbound.
 // Returns (nil, nil) when listenAllInterfaces is true or referNetworkInterface is empty.
-// Returns an error when the specified interface has no valid IPv4 address.
-func GetPreferredOutgoingBindAddr() (*net.TCPAddr, error) {
+// Returns an error when the specified interface has no valid address.
+func GetPreferredOutgoingBindAddr() (*tool.BindAddrs, error) {
 	if listenAllInterfaces || referNetworkInterface == "" {
 		return nil, nil
 	}
@@ -239,12 +378,29 @@ func GetPreferredOutgoingBindAddr() (*net.TCPAddr, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get addresses for interface %s: %w", referNetworkInterface, err)
 	}
+	bindAddrs := &tool.BindAddrs{}
 	for _, addr := range addrs {
 		ipnet, ok := addr.(*net.IPNet)
-		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.To4() == nil {
+		if !ok || ipnet.IP.IsLoopback() {
 			continue
 		}
-		return &net.TCPAddr{IP: ipnet.IP, Port: 0}, nil
+		switch {
+		case ipnet.IP.To4() != nil:
+			if bindAddrs.IPv4 == nil {
+				bindAddrs.IPv4 = &net.TCPAddr{IP: ipnet.IP, Port: 0}
+			}
+		case ipnet.IP.IsLinkLocalUnicast():
+			if bindAddrs.IPv6LinkLocal == nil {
+				bindAddrs.IPv6LinkLocal = &net.TCPAddr{IP: ipnet.IP, Port: 0, Zone: iface.Name}
+			}
+		default:
+			if bindAddrs.IPv6 == nil {
+				bindAddrs.IPv6 = &net.TCPAddr{IP: ipnet.IP, Port: 0}
+			}
+		}
+	}
+	if bindAddrs.IPv4 == nil && bindAddrs.IPv6 == nil && bindAddrs.IPv6LinkLocal == nil {
+		return nil, fmt.Errorf("interface %s has no valid address", referNetworkInterface)
 	}
-	return nil, fmt.Errorf("interface %s has no valid IPv4 address", referNetworkInterface)
+	return bindAddrs, nil
 }
@@ -0,0 +1,260 @@
+package boardcast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// Discovery channels counted per interface in the diagnostics.
+const (
+	discoveryChannelIPv4      = "ipv4"
+	discoveryChannelIPv6      = "ipv6"
+	discoveryChannelBroadcast = "broadcast"
+	discoveryChannelMDNS      = "mdns"
+)
+
+// packetOutcome is how a received discovery packet was handled.
+type packetOutcome int
+
+const (
+	packetParsed packetOutcome = iota
+	packetIgnored
+	packetInvalid
+)
+
+// selfTestTimeout bounds the wait for our own announcement in the loopback self-test
+const selfTestTimeout = 2 * time.Second
+
+type discoveryStatsKey struct {
+	iface   string
+	channel string
+}
+
+var (
+	discoveryStatsMu sync.Mutex
+	discoveryStats   = make(map[discoveryStatsKey]*types.DiscoveryInterfaceStats)
+
+	// selfTest holds the payload of a running loopback self-test and the listeners that received it.
+	selfTestMu         sync.Mutex
+	selfTestPayload    []byte
+	selfTestStarted    time.Time
+	selfTestReceivedOn []string
+	selfTestFirst      time.Duration
+	selfTestCh         chan struct{} // closed on the first reception
+)
+
+// discoveryStatsLocked returns the counters of iface on channel, creating them. discoveryStatsMu must be held.
+func discoveryStatsLocked(iface, channel string) *types.DiscoveryInterfaceStats {
+	key := discoveryStatsKey{iface: iface, channel: channel}
+	stats, ok := discoveryStats[key]
+	if !ok {
+		stats = &types.DiscoveryInterfaceStats{Interface: iface, Channel: channel}
+		discoveryStats[key] = stats
+	}
+	return stats
+}
+
+// recordJoin records the result of joining (or binding) a discovery listener.
+func recordJoin(iface, channel string, err error) {
+	discoveryStatsMu.Lock()
+	defer discoveryStatsMu.Unlock()
+	stats := discoveryStatsLocked(iface, channel)
+	stats.Joined = err == nil
+	stats.JoinError = ""
+	if err != nil {
+		stats.JoinError = err.Error()
+	}
+}
+
+// recordLeave marks a discovery listener as stopped.
+func recordLeave(iface, channel string) {
+	discoveryStatsMu.Lock()
+	defer discoveryStatsMu.Unlock()
+	discoveryStatsLocked(iface, channel).Joined = false
+}
+
+// recordReceived counts a received packet.
+func recordReceived(iface, channel string, outcome packetOutcome) {
+	discoveryStatsMu.Lock()
+	defer discoveryStatsMu.Unlock()
+	stats := discoveryStatsLocked(iface, channel)
+	stats.LastReceived = time.Now().UnixMilli()
+	switch outcome {
+	case packetParsed:
+		stats.Parsed++
+	case packetIgnored:
+		stats.Ignored++
+	default:
+		stats.Invalid++
+	}
+}
+
+// recordSent counts a sent packet or a failed send.
+func recordSent(iface, channel string, err error) {
+	discoveryStatsMu.Lock()
+	defer discoveryStatsMu.Unlock()
+	stats := discoveryStatsLocked(iface, channel)
+	if err != nil {
+		stats.SendErrors++
+		return
+	}
+	stats.Sent++
+	stats.LastSent = time.Now().UnixMilli()
+}
+
+// listDiscoveryStats returns a copy of every listener's counters, sorted by interface and channel.
+func listDiscoveryStats() []types.DiscoveryInterfaceStats {
+	discoveryStatsMu.Lock()
+	defer discoveryStatsMu.Unlock()
+	result := make([]types.DiscoveryInterfaceStats, 0, len(discoveryStats))
+	for _, stats := range discoveryStats {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Interface != result[j].Interface {
+			return result[i].Interface < result[j].Interface
+		}
+		return result[i].Channel < result[j].Channel
+	})
+	return result
+}
+
+// matchSelfTest reports whether payload is the announcement of the running self-test and records the listener.
+func matchSelfTest(payload []byte, iface, channel string) bool {
+	selfTestMu.Lock()
+	defer selfTestMu.Unlock()
+	if selfTestPayload == nil || !bytes.Equal(payload, selfTestPayload) {
+		return false
+	}
+	if len(selfTestReceivedOn) == 0 {
+		selfTestFirst = time.Since(selfTestStarted)
+		close(selfTestCh)
+	}
+	selfTestReceivedOn = append(selfTestReceivedOn, iface+"/"+channel)
+	return true
+}
+
+// RunDiscoverySelfTest sends an announcement (announce=false, so peers do not answer) and waits for our own
+// multicast and broadcast listeners to receive it. Receiving nothing points at a firewall or a missing multicast route.
+func RunDiscoverySelfTest() types.DiscoverySelfTest {
+	var result types.DiscoverySelfTest
+	multicastListenersMu.Lock()
+	self := multicastListenSelf
+	if self == nil {
+		self = multicastListenSelfV6
+	}
+	multicastListenersMu.Unlock()
+	if self == nil {
+		result.Error = "multicast listeners are not running"
+		return result
+	}
+	message := *self
+	message.Announce = false
+	payload, err := sonic.Marshal(&message)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to marshal message: %v", err)
+		return result
+	}
+
+	selfTestMu.Lock()
+	if selfTestPayload != nil {
+		selfTestMu.Unlock()
+		result.Error = "a self-test is already running"
+		return result
+	}
+	done := make(chan struct{})
+	selfTestPayload = payload
+	selfTestStarted = time.Now()
+	selfTestReceivedOn = nil
+	selfTestCh = done
+	selfTestMu.Unlock()
+
+	sendErr := sendDiscoveryPayload(payload)
+	result.Sent = sendErr == nil
+	if sendErr != nil {
+		result.Error = sendErr.Error()
+	}
+	timer := time.NewTimer(selfTestTimeout)
+	select {
+	case <-done:
+		// Give the other listeners a moment to receive their copy.
+		time.Sleep(200 * time.Millisecond)
+	case <-timer.C:
+	}
+	timer.Stop()
+
+	selfTestMu.Lock()
+	defer selfTestMu.Unlock()
+	result.ReceivedOn = selfTestReceivedOn
+	result.Received = len(selfTestReceivedOn) > 0
+	if result.Received {
+		result.LatencyMs = selfTestFirst.Milliseconds()
+	}
+	selfTestPayload = nil
+	selfTestReceivedOn = nil
+	return result
+}
+
+// sendDiscoveryPayload writes payload to the IPv4 group and, when enabled, the IPv6 group and broadcast addresses.
+func sendDiscoveryPayload(payload []byte) error {
+	var errs []error
+	if err := sendMulticastPayload(payload); err != nil {
+		errs = append(errs, err)
+	}
+	if IsIPv6MulticastEnabled() {
+		if err := sendMulticastPayloadV6(payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if IsBroadcastEnabled() {
+		if err := sendBroadcastPayload(payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetDiscoveryDiagnostics collects the discovery state for troubleshooting; selfTest also runs RunDiscoverySelfTest.
+func GetDiscoveryDiagnostics(selfTest bool) types.DiscoveryDiagnostics {
+	diagnostics := types.DiscoveryDiagnostics{
+		ScanPauseCount: scanPauseCount.Load(),
+		Probe:          tool.GetProbeStatus(),
+		Register:       GetRegisterResponseStats(),
+	}
+	diagnostics.ScanPaused = diagnostics.ScanPauseCount > 0
+	if config := GetScanConfig(); config != nil {
+		diagnostics.Mode = config.Mode.String()
+	}
+	autoScanControlMu.Lock()
+	diagnostics.AutoScanRunning = types.AutoScanRunningStatus{
+		HTTP: autoScanHTTPRunning,
+		UDP:  autoScanUDPRunning,
+		MDNS: autoScanMDNSRunning,
+	}
+	autoScanControlMu.Unlock()
+
+	progress := GetHTTPScanProgress()
+	diagnostics.HTTPScan = types.HTTPScanDiagnostics{ScanProgress: progress}
+	if progress.Targets > 0 {
+		diagnostics.HTTPScan.Coverage = float64(progress.Scanned) / float64(progress.Targets)
+	}
+	if progress.Scanned > 0 {
+		diagnostics.HTTPScan.HitRate = float64(progress.Found) / float64(progress.Scanned)
+	}
+
+	if selfTest {
+		result := RunDiscoverySelfTest()
+		diagnostics.SelfTest = &result
+	}
+	// Collected last so the counters include the self-test.
+	diagnostics.Interfaces = listDiscoveryStats()
+	return diagnostics
+}
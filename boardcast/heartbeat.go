@@ -0,0 +1,124 @@
+package boardcast
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	// heartbeatMissLimit is the number of consecutive failed heartbeats before a device is reported lost.
+	heartbeatMissLimit = 3
+	// heartbeatConcurrency caps concurrent /info probes per heartbeat round.
+	heartbeatConcurrency = 8
+)
+
+// StartDeviceHeartbeat periodically GETs /info of every device in scan-current. A successful probe
+// refreshes LastSeen; after heartbeatMissLimit consecutive failures the device is removed and
+// device_lost is emitted. interval <= 0 disables the heartbeat.
+func StartDeviceHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	tool.DefaultLogger.Infof("Starting device heartbeat (every %v)", interval)
+	misses := make(map[string]int)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if IsScanPaused() {
+			tool.DefaultLogger.Debug("Device heartbeat: paused, skipping this tick")
+			continue
+		}
+		heartbeatOnce(misses)
+	}
+}
+
+// heartbeatOnce probes all known devices once and updates the consecutive miss counters.
+func heartbeatOnce(misses map[string]int) {
+	keys := share.ListUserScanCurrent()
+	alive := make(map[string]bool, len(keys))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, heartbeatConcurrency)
+	for _, key := range keys {
+		item, ok := share.GetUserScanCurrent(key)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string, item types.UserScanCurrentItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ok := probeDeviceInfo(item)
+			mu.Lock()
+			alive[key] = ok
+			mu.Unlock()
+		}(key, item)
+	}
+	wg.Wait()
+
+	for key := range misses {
+		if _, known := alive[key]; !known {
+			delete(misses, key)
+		}
+	}
+	for key, ok := range alive {
+		if ok {
+			delete(misses, key)
+			share.MarkUserScanCurrentSeen(key)
+			continue
+		}
+		misses[key]++
+		tool.DefaultLogger.Debugf("Device heartbeat: %s missed %d/%d", key, misses[key], heartbeatMissLimit)
+		if misses[key] >= heartbeatMissLimit {
+			delete(misses, key)
+			share.RemoveLostUserScanCurrent(key)
+		}
+	}
+}
+
+// probeDeviceInfo GETs /info of item and reports whether the same device answered.
+func probeDeviceInfo(item types.UserScanCurrentItem) bool {
+	protocol := item.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+	port := item.Port
+	if port == 0 {
+		port = multcastPort
+	}
+	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("GET", tool.BuildInfoURL(protocol, item.Ipaddress, port), nil))
+	if err != nil {
+		return false
+	}
+	req = req.WithContext(tool.WithPeerFingerprint(req.Context(), item.Fingerprint))
+	resp, err := tool.GetScanHttpClient().Do(req)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return false
+	}
+	var remote types.CallbackLegacyVersionMessageHTTP
+	if err := sonic.Unmarshal(body, &remote); err != nil {
+		return false
+	}
+	// Another device took over the address.
+	return remote.Fingerprint == "" || remote.Fingerprint == item.Fingerprint
+}
@@ -17,18 +17,21 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// HTTPScanOptions configures concurrency and ICMP rate limit for HTTP scan.
+// HTTPScanOptions configures concurrency and host probe rate limit for HTTP scan.
 // Concurrency: max concurrent scan goroutines; 0 or large value = effectively unlimited (e.g. scan-now).
-// RateLimitPPS: ICMP probe rate limit (packets per second); 0 = no limit.
+// RateLimitPPS: host probe rate limit (packets per second); 0 = no limit. Raised for large chunks, see adaptiveScanRate.
+// MaxTargets: max addresses probed per call; larger target lists continue where the previous call stopped.
 type HTTPScanOptions struct {
-	Concurrency  int // max concurrent workers
-	RateLimitPPS int // 0 = no rate limit
+	Concurrency  int             // max concurrent workers
+	RateLimitPPS int             // 0 = no rate limit
+	MaxTargets   int             // 0 = scan all targets in one call
+	sweep        *httpSweepState // cursor to continue from; nil = the auto scan cursor (httpSweep)
 }
 
-// scanOneIPHTTP performs ICMP probe (host reachability), then POST register (https then http on EOF), parses response and stores device via share.SetUserScanCurrent.
+// scanOneIPHTTP performs a host probe (see tool.ProbeHost), then POST register (https then http on EOF), parses response and stores device via share.SetUserScanCurrent.
 // Used by ListenMulticastUsingHTTPWithTimeout and ScanOnceHTTP. Returns true if a device was discovered and stored.
 func scanOneIPHTTP(targetIP string, payloadBytes []byte, httpClient *http.Client) bool {
-	if !tool.QuickICMPProbe(targetIP, icmpProbeTimeout) {
+	if !tool.ProbeHost(targetIP, multcastPort, hostProbeTimeout) {
 		return false
 	}
 	protocol := "https"
@@ -75,10 +78,14 @@ func scanOneIPHTTP(targetIP string, payloadBytes []byte, httpClient *http.Client
 	if err := sonic.Unmarshal(body, &remote); err != nil {
 		return false
 	}
+	if err := tool.VerifyResponsePeerFingerprint(resp, remote.Fingerprint); err != nil {
+		return false
+	}
 	tool.DefaultLogger.Infof("scanOneIPHTTP: discovered device at %s: %s (fingerprint: %s)", urlStr, remote.Alias, remote.Fingerprint)
 	if remote.Fingerprint != "" {
 		share.SetUserScanCurrent(remote.Fingerprint, types.UserScanCurrentItem{
 			Ipaddress: targetIP,
+			Source:    types.DeviceSourceHTTPScan,
 			VersionMessage: types.VersionMessage{
 				Alias:       remote.Alias,
 				Version:     remote.Version,
@@ -96,12 +103,13 @@ func scanOneIPHTTP(targetIP string, payloadBytes []byte, httpClient *http.Client
 	return false
 }
 
-// sendRegisterRequest sends a register request to the remote device.
-func sendRegisterRequest(url string, payload string) error {
+// sendRegisterRequest sends a register request to the remote device, which must present the certificate of fingerprint.
+func sendRegisterRequest(url string, payload string, fingerprint string) error {
 	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("POST", url, bytes.NewReader(tool.StringToBytes(payload))))
 	if err != nil {
 		return fmt.Errorf("failed to create register request: %v", err)
 	}
+	req = req.WithContext(tool.WithPeerFingerprint(req.Context(), fingerprint))
 	tool.DefaultLogger.Debugf("Sent: %s, using Payload: %s", url, payload)
 
 	resp, err := tool.GetHttpClient().Do(req)
@@ -129,16 +137,17 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 	}
 
 	autoScanControlMu.Lock()
-	autoScanHTTPRunning = true
+	autoScanHTTPRunning++
 	if autoScanRestartCh == nil {
 		autoScanRestartCh = make(chan restartAction, 1)
 	}
 	restartCh := autoScanRestartCh
+	stopCh := autoScanStopChannelLocked()
 	autoScanControlMu.Unlock()
 
 	defer func() {
 		autoScanControlMu.Lock()
-		autoScanHTTPRunning = false
+		autoScanHTTPRunning--
 		autoScanControlMu.Unlock()
 	}()
 
@@ -176,7 +185,7 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 	startTime := time.Now()
 
 	scanOnce := func() {
-		opts := &HTTPScanOptions{Concurrency: autoScanConcurrencyLimit, RateLimitPPS: autoScanICMPRatePPS}
+		opts := &HTTPScanOptions{Concurrency: autoScanConcurrencyLimit, RateLimitPPS: autoScanICMPRatePPS, MaxTargets: autoScanChunkSize}
 		if err := ScanOnceHTTP(self, opts); err != nil {
 			tool.DefaultLogger.Warnf("ListenMulticastUsingHTTP: scan failed: %v", err)
 		}
@@ -194,6 +203,9 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 			elapsed := time.Since(startTime)
 			tool.DefaultLogger.Infof("HTTP scanning stopped after timeout (%v elapsed)", elapsed.Round(time.Second))
 			return
+		case <-stopCh:
+			tool.DefaultLogger.Info("HTTP scanning stopped")
+			return
 		case action := <-restartCh:
 			resetTimeout()
 			startTime = time.Now()
@@ -229,14 +241,13 @@ func ScanOnceHTTP(self *types.VersionMessageHTTP, opts *HTTPScanOptions) error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal self message: %v", err)
 	}
-	targets, err := getCachedNetworkIPs()
+	targets, targetsKey, err := getCachedNetworkIPs()
 	if err != nil {
 		return fmt.Errorf("failed to get network IPs: %v", err)
 	}
 	if len(targets) == 0 {
 		return fmt.Errorf("no usable local IPv4 addresses found")
 	}
-	tool.DefaultLogger.Debugf("ScanOnceHTTP: scanning %d IP addresses (concurrency=%d, ratePPS=%d)", len(targets), concurrency, opts.RateLimitPPS)
 
 	selfIPs := tool.GetLocalIPv4Set()
 	filtered := targets[:0]
@@ -246,15 +257,26 @@ func ScanOnceHTTP(self *types.VersionMessageHTTP, opts *HTTPScanOptions) error {
 		}
 		filtered = append(filtered, ip)
 	}
-	targets = filtered
+	chunk := len(filtered)
+	if opts.MaxTargets > 0 && opts.MaxTargets < chunk {
+		chunk = opts.MaxTargets
+	}
+	ratePPS := adaptiveScanRate(chunk, opts.RateLimitPPS)
+	sweep := opts.sweep
+	if sweep == nil {
+		sweep = &httpSweep
+	}
+	targets = sweep.next(targetsKey, filtered, opts.MaxTargets, ratePPS)
+	defer sweep.done(len(targets))
+	tool.DefaultLogger.Debugf("ScanOnceHTTP: scanning %d of %d IP addresses (concurrency=%d, ratePPS=%d)", len(targets), len(filtered), concurrency, ratePPS)
 
 	var limiter *rate.Limiter
-	if opts.RateLimitPPS > 0 {
-		burst := opts.RateLimitPPS + 10
+	if ratePPS > 0 {
+		burst := ratePPS + 10
 		if burst < 20 {
 			burst = 20
 		}
-		limiter = rate.NewLimiter(rate.Limit(opts.RateLimitPPS), burst)
+		limiter = rate.NewLimiter(rate.Limit(ratePPS), burst)
 	}
 
 	sem := make(chan struct{}, concurrency)
@@ -271,7 +293,7 @@ func ScanOnceHTTP(self *types.VersionMessageHTTP, opts *HTTPScanOptions) error {
 					return
 				}
 			}
-			scanOneIPHTTP(targetIP, payloadBytes, tool.GetScanHttpClient())
+			sweep.probed(scanOneIPHTTP(targetIP, payloadBytes, tool.GetScanHttpClient()))
 		}(ip)
 	}
 	wg.Wait()
@@ -0,0 +1,615 @@
+package boardcast
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+// mDNS / DNS-SD discovery (RFC 6762, RFC 6763) for networks that filter the LocalSend multicast group but pass mDNS.
+const (
+	mdnsAddress = "224.0.0.251"
+	mdnsPort    = 5353
+	// MDNSServiceType is the DNS-SD service type advertised and browsed in mDNS scan mode.
+	MDNSServiceType = "_localsend._tcp.local."
+	// mdnsRecordTTL is the TTL in seconds of the advertised records
+	mdnsRecordTTL = 120
+	// mdnsCacheFlush is the cache-flush bit set on the class of unique records (RFC 6762 10.2)
+	mdnsCacheFlush = 0x8000
+	// mdnsMaxAliasLabel caps the alias part of the instance label (labels are at most 63 bytes)
+	mdnsMaxAliasLabel = 50
+	// mdnsMaxTXTString is the length limit of a single TXT record string
+	mdnsMaxTXTString  = 255
+	mdnsMaxPacketSize = 9000
+)
+
+var (
+	mdnsMu     sync.Mutex // guards the fields below and serializes writes (SetMulticastInterface + WriteTo)
+	mdnsConn   *ipv4.PacketConn
+	mdnsIfaces []*net.Interface
+	mdnsSelf   *types.VersionMessage
+)
+
+// ListenMDNS advertises self as a DNS-SD service on every discovery interface, answers queries for it
+// and records peers found in mDNS responses in scan-current. It blocks.
+func ListenMDNS(self *types.VersionMessage) {
+	group := &net.UDPAddr{IP: net.ParseIP(mdnsAddress), Port: mdnsPort}
+	interfaces, err := getNetworkInterfaces()
+	if err != nil {
+		tool.DefaultLogger.Errorf("mDNS discovery disabled: %v", err)
+		return
+	}
+	// One socket joined on every interface; ListenMulticastUDP sets SO_REUSEADDR so a system responder can share the port.
+	c, err := net.ListenMulticastUDP("udp4", interfaces[0], group)
+	recordJoin(multicastInterfaceName(interfaces[0]), discoveryChannelMDNS, err)
+	if err != nil {
+		tool.DefaultLogger.Errorf("Failed to listen on mDNS address %s: %v", group.String(), err)
+		return
+	}
+	defer func() {
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			tool.DefaultLogger.Errorf("Failed to close mDNS connection: %v", err)
+		}
+	}()
+	pc := ipv4.NewPacketConn(c)
+	for _, iface := range interfaces[1:] {
+		err := pc.JoinGroup(iface, group)
+		recordJoin(multicastInterfaceName(iface), discoveryChannelMDNS, err)
+		if err != nil {
+			tool.DefaultLogger.Warnf("Failed to join mDNS group on interface %s: %v", iface.Name, err)
+		}
+	}
+	// The receiving interface selects which addresses go into the answer; unsupported on Windows (answers go to every interface).
+	if err := pc.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		tool.DefaultLogger.Debugf("mDNS: interface control messages unavailable: %v", err)
+	}
+	if err := pc.SetMulticastTTL(255); err != nil {
+		tool.DefaultLogger.Debugf("mDNS: failed to set multicast TTL: %v", err)
+	}
+	if err := pc.SetMulticastLoopback(true); err != nil {
+		tool.DefaultLogger.Debugf("mDNS: failed to enable multicast loopback: %v", err)
+	}
+
+	mdnsMu.Lock()
+	mdnsConn = pc
+	mdnsIfaces = interfaces
+	mdnsSelf = self
+	mdnsMu.Unlock()
+	defer func() {
+		mdnsMu.Lock()
+		if mdnsConn == pc {
+			mdnsConn = nil
+		}
+		for _, iface := range mdnsIfaces {
+			recordLeave(multicastInterfaceName(iface), discoveryChannelMDNS)
+		}
+		mdnsMu.Unlock()
+	}()
+	tool.DefaultLogger.Infof("Advertising %s as %q on mDNS (%d interfaces)", MDNSServiceType, mdnsInstanceName(self), len(interfaces))
+
+	go func() {
+		// RFC 6762 8.3: announce at least twice, one second apart.
+		for i := 0; i < 2; i++ {
+			announceMDNS()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	buf := make([]byte, mdnsMaxPacketSize)
+	for {
+		n, cm, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				tool.DefaultLogger.Info("Stopped mDNS advertisement")
+			} else {
+				tool.DefaultLogger.Errorf("Error reading from mDNS: %v", err)
+			}
+			return
+		}
+		ifIndex := 0
+		if cm != nil {
+			ifIndex = cm.IfIndex
+		}
+		interfaceName := "default"
+		if iface, err := net.InterfaceByIndex(ifIndex); ifIndex != 0 && err == nil {
+			interfaceName = iface.Name
+		}
+		recordReceived(interfaceName, discoveryChannelMDNS, handleMDNSPacket(buf[:n], ifIndex, from, self))
+	}
+}
+
+// StopMDNS stops the mDNS responder started by ListenMDNS.
+func StopMDNS() {
+	mdnsMu.Lock()
+	defer mdnsMu.Unlock()
+	if mdnsConn == nil {
+		return
+	}
+	if err := mdnsConn.Close(); err != nil {
+		tool.DefaultLogger.Errorf("Failed to close mDNS connection: %v", err)
+	}
+	mdnsConn = nil
+}
+
+// BrowseMDNSWithTimeout queries for LocalSend services every 30 seconds until timeout (seconds, 0 = no timeout).
+// Supports restart via RestartAutoScan() which resets the timeout timer. ListenMDNS must be running to receive the answers.
+func BrowseMDNSWithTimeout(timeout int) {
+	autoScanControlMu.Lock()
+	autoScanMDNSRunning++
+	if autoScanRestartCh == nil {
+		autoScanRestartCh = make(chan restartAction, 1)
+	}
+	restartCh := autoScanRestartCh
+	stopCh := autoScanStopChannelLocked()
+	autoScanControlMu.Unlock()
+
+	defer func() {
+		autoScanControlMu.Lock()
+		autoScanMDNSRunning--
+		autoScanControlMu.Unlock()
+	}()
+
+	var timeoutCh <-chan time.Time
+	var timeoutTimer *time.Timer
+	if timeout > 0 {
+		tool.DefaultLogger.Infof("Starting mDNS browsing (every 30 seconds, timeout: %d seconds)", timeout)
+		timeoutTimer = time.NewTimer(time.Duration(timeout) * time.Second)
+		timeoutCh = timeoutTimer.C
+		defer timeoutTimer.Stop()
+	} else {
+		tool.DefaultLogger.Info("Starting mDNS browsing (every 30 seconds, no timeout)")
+	}
+
+	queryOnce := func() {
+		if err := SendMDNSQueryOnce(); err != nil {
+			tool.DefaultLogger.Warnf("mDNS query failed: %v", err)
+		}
+	}
+	// Give ListenMDNS a moment to open the socket on startup.
+	time.Sleep(time.Second)
+	queryOnce()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timeoutCh:
+			tool.DefaultLogger.Info("mDNS browsing stopped after timeout")
+			return
+		case <-stopCh:
+			tool.DefaultLogger.Info("mDNS browsing stopped")
+			return
+		case <-restartCh:
+			if timeoutTimer != nil {
+				timeoutTimer.Reset(time.Duration(timeout) * time.Second)
+			}
+			queryOnce()
+		case <-ticker.C:
+			if IsScanPaused() {
+				tool.DefaultLogger.Debug("mDNS browse: paused, skipping this tick")
+				continue
+			}
+			queryOnce()
+		}
+	}
+}
+
+// SendMDNSQueryOnce sends a PTR query for MDNSServiceType on every mDNS interface.
+func SendMDNSQueryOnce() error {
+	query, err := buildMDNSQuery()
+	if err != nil {
+		return fmt.Errorf("failed to build mDNS query: %v", err)
+	}
+	mdnsMu.Lock()
+	defer mdnsMu.Unlock()
+	if mdnsConn == nil {
+		return fmt.Errorf("mDNS listener is not running")
+	}
+	var lastErr error
+	for _, iface := range mdnsIfaces {
+		if err := writeMDNSMulticastLocked(query, iface); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// refreshMDNSInterfaces re-joins the mDNS group on the current discovery interfaces after a network change.
+func refreshMDNSInterfaces() {
+	mdnsMu.Lock()
+	defer mdnsMu.Unlock()
+	if mdnsConn == nil {
+		return
+	}
+	interfaces, err := getNetworkInterfaces()
+	if err != nil {
+		tool.DefaultLogger.Warnf("mDNS is waiting for a network interface: %v", err)
+		mdnsIfaces = nil
+		return
+	}
+	group := &net.UDPAddr{IP: net.ParseIP(mdnsAddress), Port: mdnsPort}
+	for _, iface := range interfaces {
+		// Leave first so a changed interface gets a fresh membership; errors only mean "not joined yet".
+		_ = mdnsConn.LeaveGroup(iface, group)
+		err := mdnsConn.JoinGroup(iface, group)
+		recordJoin(multicastInterfaceName(iface), discoveryChannelMDNS, err)
+		if err != nil {
+			tool.DefaultLogger.Warnf("Failed to join mDNS group on interface %s: %v", multicastInterfaceName(iface), err)
+		}
+	}
+	mdnsIfaces = interfaces
+}
+
+// announceMDNS sends an unsolicited response with our records on every mDNS interface.
+func announceMDNS() {
+	mdnsMu.Lock()
+	defer mdnsMu.Unlock()
+	if mdnsConn == nil || mdnsSelf == nil {
+		return
+	}
+	for _, iface := range mdnsIfaces {
+		if err := respondMDNSLocked(iface, 0, nil, nil); err != nil {
+			tool.DefaultLogger.Warnf("mDNS announce failed: %v", err)
+		}
+	}
+}
+
+// handleMDNSPacket answers queries for our records and ingests responses from other LocalSend nodes.
+// ifIndex is the receiving interface (0 if unknown).
+func handleMDNSPacket(payload []byte, ifIndex int, from net.Addr, self *types.VersionMessage) packetOutcome {
+	var p dnsmessage.Parser
+	header, err := p.Start(payload)
+	if err != nil {
+		tool.DefaultLogger.Debugf("mDNS: malformed packet from %v: %v", from, err)
+		return packetInvalid
+	}
+	udpAddr, ok := from.(*net.UDPAddr)
+	if !ok {
+		return packetInvalid
+	}
+
+	if header.Response {
+		items, err := parseMDNSResponse(payload, udpAddr.IP)
+		if err != nil {
+			tool.DefaultLogger.Debugf("mDNS: failed to parse response from %v: %v", from, err)
+			return packetInvalid
+		}
+		outcome := packetIgnored
+		for _, item := range items {
+			if self != nil && item.Fingerprint == self.Fingerprint {
+				continue
+			}
+			share.SetUserScanCurrent(item.Fingerprint, item)
+			outcome = packetParsed
+		}
+		return outcome
+	}
+
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return packetInvalid
+	}
+	if !mdnsQuestionsMatch(questions, self) {
+		return packetIgnored
+	}
+	mdnsMu.Lock()
+	defer mdnsMu.Unlock()
+	if mdnsConn == nil {
+		return packetIgnored
+	}
+	for _, iface := range mdnsIfaces {
+		if ifIndex != 0 && iface != nil && iface.Index != ifIndex {
+			continue
+		}
+		if udpAddr.Port != mdnsPort {
+			// Legacy unicast query (e.g. dig -p 5353 @224.0.0.251): reply to the sender with its ID and questions (RFC 6762 6.7).
+			err = respondMDNSLocked(iface, header.ID, questions, udpAddr)
+		} else {
+			err = respondMDNSLocked(iface, 0, nil, nil)
+		}
+		if err != nil {
+			tool.DefaultLogger.Warnf("mDNS response failed: %v", err)
+		}
+	}
+	return packetParsed
+}
+
+// respondMDNSLocked sends our records with the addresses of iface, multicast or to unicastTo. mdnsMu must be held.
+func respondMDNSLocked(iface *net.Interface, id uint16, questions []dnsmessage.Question, unicastTo *net.UDPAddr) error {
+	response, err := buildMDNSResponse(mdnsSelf, mdnsInterfaceIPv4s(iface), id, questions)
+	if err != nil {
+		return fmt.Errorf("failed to build mDNS response: %v", err)
+	}
+	if unicastTo != nil {
+		_, err = mdnsConn.WriteTo(response, nil, unicastTo)
+		recordSent(multicastInterfaceName(iface), discoveryChannelMDNS, err)
+		return err
+	}
+	return writeMDNSMulticastLocked(response, iface)
+}
+
+// writeMDNSMulticastLocked writes payload to the mDNS group on iface (nil = system default). mdnsMu must be held.
+func writeMDNSMulticastLocked(payload []byte, iface *net.Interface) error {
+	if iface != nil {
+		if err := mdnsConn.SetMulticastInterface(iface); err != nil {
+			return fmt.Errorf("failed to select interface %s: %v", iface.Name, err)
+		}
+	}
+	_, err := mdnsConn.WriteTo(payload, nil, &net.UDPAddr{IP: net.ParseIP(mdnsAddress), Port: mdnsPort})
+	recordSent(multicastInterfaceName(iface), discoveryChannelMDNS, err)
+	if err != nil && iface != nil {
+		return fmt.Errorf("interface %s: %v", iface.Name, err)
+	}
+	return err
+}
+
+// mdnsInterfaceIPv4s returns the IPv4 addresses of iface, or of every interface when iface is nil.
+func mdnsInterfaceIPv4s(iface *net.Interface) []net.IP {
+	var addrs []net.Addr
+	var err error
+	if iface != nil {
+		addrs, err = iface.Addrs()
+	} else {
+		addrs, err = net.InterfaceAddrs()
+	}
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			ips = append(ips, ip4)
+		}
+	}
+	return ips
+}
+
+// mdnsLabel makes s usable as a single DNS label: dots become dashes and it is cut to maxLen bytes on a rune boundary.
+func mdnsLabel(s string, maxLen int) string {
+	return truncateUTF8(strings.NewReplacer(".", "-", "\\", "-").Replace(strings.TrimSpace(s)), maxLen)
+}
+
+// truncateUTF8 cuts s to at most maxLen bytes on a rune boundary.
+func truncateUTF8(s string, maxLen int) string {
+	for len(s) > maxLen {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+func mdnsShortFingerprint(self *types.VersionMessage) string {
+	return mdnsLabel(self.Fingerprint, 8)
+}
+
+// mdnsInstanceName returns the service instance name, "<alias>-<fingerprint prefix>._localsend._tcp.local.".
+// The fingerprint prefix keeps two devices with the same alias apart.
+func mdnsInstanceName(self *types.VersionMessage) string {
+	alias := mdnsLabel(self.Alias, mdnsMaxAliasLabel)
+	if alias == "" {
+		alias = "LocalSend"
+	}
+	return alias + "-" + mdnsShortFingerprint(self) + "." + MDNSServiceType
+}
+
+// mdnsHostName returns the host name the SRV record points to.
+func mdnsHostName(self *types.VersionMessage) string {
+	return "localsend-" + mdnsShortFingerprint(self) + ".local."
+}
+
+// mdnsTXT returns the TXT record strings describing self, each cut to mdnsMaxTXTString bytes so a long alias
+// cannot make the record unencodable.
+func mdnsTXT(self *types.VersionMessage) []string {
+	txt := []string{
+		"alias=" + self.Alias,
+		"version=" + self.Version,
+		"deviceModel=" + self.DeviceModel,
+		"deviceType=" + self.DeviceType,
+		"fingerprint=" + self.Fingerprint,
+		"protocol=" + self.Protocol,
+		"download=" + strconv.FormatBool(self.Download),
+	}
+	for i := range txt {
+		txt[i] = truncateUTF8(txt[i], mdnsMaxTXTString)
+	}
+	return txt
+}
+
+// mdnsQuestionsMatch reports whether any question asks for the service type, our instance or our host name.
+func mdnsQuestionsMatch(questions []dnsmessage.Question, self *types.VersionMessage) bool {
+	if self == nil {
+		return false
+	}
+	for _, q := range questions {
+		switch strings.ToLower(q.Name.String()) {
+		case strings.ToLower(MDNSServiceType), strings.ToLower(mdnsInstanceName(self)), strings.ToLower(mdnsHostName(self)):
+			return true
+		}
+	}
+	return false
+}
+
+// buildMDNSQuery builds a PTR query for MDNSServiceType.
+func buildMDNSQuery() ([]byte, error) {
+	service, err := dnsmessage.NewName(MDNSServiceType)
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: service, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// buildMDNSResponse builds a response carrying the PTR, SRV, TXT and A records of self.
+// id and questions are only set for legacy unicast replies.
+func buildMDNSResponse(self *types.VersionMessage, ips []net.IP, id uint16, questions []dnsmessage.Question) ([]byte, error) {
+	if self == nil {
+		return nil, fmt.Errorf("missing self message")
+	}
+	service, err := dnsmessage.NewName(MDNSServiceType)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := dnsmessage.NewName(mdnsInstanceName(self))
+	if err != nil {
+		return nil, err
+	}
+	host, err := dnsmessage.NewName(mdnsHostName(self))
+	if err != nil {
+		return nil, err
+	}
+	port := self.Port
+	if port == 0 {
+		port = multcastPort
+	}
+	unique := func(name dnsmessage.Name) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET | mdnsCacheFlush, TTL: mdnsRecordTTL}
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, Authoritative: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		if err := b.Question(q); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if err := b.PTRResource(dnsmessage.ResourceHeader{Name: service, Class: dnsmessage.ClassINET, TTL: mdnsRecordTTL},
+		dnsmessage.PTRResource{PTR: instance}); err != nil {
+		return nil, err
+	}
+	if err := b.SRVResource(unique(instance), dnsmessage.SRVResource{Port: uint16(port), Target: host}); err != nil {
+		return nil, err
+	}
+	if err := b.TXTResource(unique(instance), dnsmessage.TXTResource{TXT: mdnsTXT(self)}); err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			continue
+		}
+		if err := b.AResource(unique(host), dnsmessage.AResource{A: [4]byte(ip4)}); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// parseMDNSResponse returns the LocalSend devices described by an mDNS response.
+// A device needs a TXT record with a fingerprint; its address comes from the A record of the SRV target,
+// falling back to from (the packet source). Records with TTL 0 (goodbye) are ignored.
+func parseMDNSResponse(payload []byte, from net.IP) ([]types.UserScanCurrentItem, error) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(payload); err != nil {
+		return nil, err
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+	records, err := p.AllAnswers()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return nil, err
+	}
+	additionals, err := p.AllAdditionals()
+	if err != nil {
+		return nil, err
+	}
+	records = append(records, additionals...)
+
+	serviceSuffix := "." + strings.ToLower(MDNSServiceType)
+	srvs := make(map[string]dnsmessage.SRVResource)
+	txts := make(map[string][]string)
+	addrs := make(map[string]net.IP)
+	var instances []string
+	for _, r := range records {
+		if r.Header.TTL == 0 {
+			continue
+		}
+		name := strings.ToLower(r.Header.Name.String())
+		switch body := r.Body.(type) {
+		case *dnsmessage.SRVResource:
+			srvs[name] = *body
+		case *dnsmessage.TXTResource:
+			if strings.HasSuffix(name, serviceSuffix) {
+				if _, ok := txts[name]; !ok {
+					instances = append(instances, name)
+				}
+				txts[name] = body.TXT
+			}
+		case *dnsmessage.AResource:
+			if _, ok := addrs[name]; !ok {
+				addrs[name] = net.IP(body.A[:])
+			}
+		}
+	}
+
+	var items []types.UserScanCurrentItem
+	for _, name := range instances {
+		fields := make(map[string]string)
+		for _, entry := range txts[name] {
+			if key, value, ok := strings.Cut(entry, "="); ok {
+				fields[key] = value
+			}
+		}
+		if fields["fingerprint"] == "" {
+			continue
+		}
+		ip := from
+		port := multcastPort
+		if srv, ok := srvs[name]; ok {
+			port = int(srv.Port)
+			if addr, ok := addrs[strings.ToLower(srv.Target.String())]; ok {
+				ip = addr
+			}
+		}
+		if ip == nil {
+			continue
+		}
+		download, _ := strconv.ParseBool(fields["download"])
+		items = append(items, types.UserScanCurrentItem{
+			Ipaddress: tool.IPStringWithZone(ip, ""),
+			Source:    types.DeviceSourceMDNS,
+			VersionMessage: types.VersionMessage{
+				Alias:       fields["alias"],
+				Version:     fields["version"],
+				DeviceModel: fields["deviceModel"],
+				DeviceType:  fields["deviceType"],
+				Fingerprint: fields["fingerprint"],
+				Port:        port,
+				Protocol:    fields["protocol"],
+				Download:    download,
+				Announce:    true,
+			},
+		})
+	}
+	return items, nil
+}
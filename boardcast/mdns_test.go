@@ -0,0 +1,191 @@
+package boardcast
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/types"
+	"golang.org/x/net/ipv4"
+)
+
+// loopbackInterface returns the loopback interface; its addresses are left out of the A records, so answers
+// point at the packet source (127.0.0.1).
+func loopbackInterface(t *testing.T) *net.Interface {
+	t.Helper()
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("cannot list interfaces: %v", err)
+	}
+	for i := range interfaces {
+		if interfaces[i].Flags&net.FlagLoopback != 0 && interfaces[i].Flags&net.FlagUp != 0 {
+			return &interfaces[i]
+		}
+	}
+	t.Skip("no loopback interface")
+	return nil
+}
+
+// startLoopbackResponder advertises self from a socket on 127.0.0.1 the way ListenMDNS does from the mDNS group,
+// and returns the address queries must be sent to.
+func startLoopbackResponder(t *testing.T, self *types.VersionMessage) *net.UDPAddr {
+	t.Helper()
+	lo := loopbackInterface(t)
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	pc := ipv4.NewPacketConn(c)
+
+	mdnsMu.Lock()
+	prevConn, prevIfaces, prevSelf := mdnsConn, mdnsIfaces, mdnsSelf
+	mdnsConn, mdnsIfaces, mdnsSelf = pc, []*net.Interface{lo}, self
+	mdnsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, mdnsMaxPacketSize)
+		for {
+			n, _, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			handleMDNSPacket(buf[:n], 0, from, self)
+		}
+	}()
+	t.Cleanup(func() {
+		_ = c.Close()
+		<-done
+		mdnsMu.Lock()
+		mdnsConn, mdnsIfaces, mdnsSelf = prevConn, prevIfaces, prevSelf
+		mdnsMu.Unlock()
+	})
+	return c.LocalAddr().(*net.UDPAddr)
+}
+
+// browseLoopback sends a PTR query to responder from another loopback socket (a legacy unicast query, answered
+// directly) and hands the answer to handleMDNSPacket like ListenMDNS would.
+func browseLoopback(t *testing.T, responder *net.UDPAddr, browser *types.VersionMessage) packetOutcome {
+	t.Helper()
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer c.Close()
+	query, err := buildMDNSQuery()
+	if err != nil {
+		t.Fatalf("build query: %v", err)
+	}
+	if _, err := c.WriteToUDP(query, responder); err != nil {
+		t.Fatalf("send query: %v", err)
+	}
+	if err := c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	buf := make([]byte, mdnsMaxPacketSize)
+	n, from, err := c.ReadFromUDP(buf)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Fatal("no mDNS answer over loopback")
+		}
+		t.Fatalf("read answer: %v", err)
+	}
+	return handleMDNSPacket(buf[:n], 0, from, browser)
+}
+
+func TestMDNSLoopbackDiscovery(t *testing.T) {
+	self := &types.VersionMessage{
+		Alias:       "Desk v1.2 ünïcode",
+		Version:     "2.1",
+		DeviceModel: "steamdeck",
+		DeviceType:  "headless",
+		Fingerprint: "mdns-test-advertised-fingerprint",
+		Port:        53318,
+		Protocol:    "https",
+		Download:    true,
+	}
+	browser := &types.VersionMessage{Alias: "Browser", Fingerprint: "mdns-test-browser-fingerprint"}
+	t.Cleanup(share.ClearUserScanCurrent)
+
+	responder := startLoopbackResponder(t, self)
+	if outcome := browseLoopback(t, responder, browser); outcome != packetParsed {
+		t.Fatalf("answer outcome = %v, want packetParsed", outcome)
+	}
+
+	item, ok := share.GetUserScanCurrent(self.Fingerprint)
+	if !ok {
+		t.Fatalf("device %s not in scan-current", self.Fingerprint)
+	}
+	if item.Alias != self.Alias {
+		t.Errorf("alias = %q, want %q", item.Alias, self.Alias)
+	}
+	if item.Fingerprint != self.Fingerprint {
+		t.Errorf("fingerprint = %q, want %q", item.Fingerprint, self.Fingerprint)
+	}
+	if item.Protocol != self.Protocol {
+		t.Errorf("protocol = %q, want %q", item.Protocol, self.Protocol)
+	}
+	if item.Download != self.Download {
+		t.Errorf("download = %v, want %v", item.Download, self.Download)
+	}
+	if item.Version != self.Version || item.DeviceModel != self.DeviceModel || item.DeviceType != self.DeviceType {
+		t.Errorf("device info = %q/%q/%q, want %q/%q/%q", item.Version, item.DeviceModel, item.DeviceType,
+			self.Version, self.DeviceModel, self.DeviceType)
+	}
+	if item.Port != self.Port {
+		t.Errorf("port = %d, want %d", item.Port, self.Port)
+	}
+	if item.Ipaddress != "127.0.0.1" {
+		t.Errorf("ip = %q, want 127.0.0.1", item.Ipaddress)
+	}
+	if item.Source != types.DeviceSourceMDNS {
+		t.Errorf("source = %q, want %q", item.Source, types.DeviceSourceMDNS)
+	}
+}
+
+func TestMDNSLoopbackLongAlias(t *testing.T) {
+	self := &types.VersionMessage{
+		Alias:       strings.Repeat("ä", 200),
+		Fingerprint: "mdns-test-long-alias-fingerprint",
+		Port:        53318,
+		Protocol:    "https",
+	}
+	browser := &types.VersionMessage{Alias: "Browser", Fingerprint: "mdns-test-browser-fingerprint"}
+	t.Cleanup(share.ClearUserScanCurrent)
+
+	for _, s := range mdnsTXT(self) {
+		if len(s) > mdnsMaxTXTString || !utf8.ValidString(s) {
+			t.Fatalf("TXT string of %d bytes (valid UTF-8: %v)", len(s), utf8.ValidString(s))
+		}
+	}
+	responder := startLoopbackResponder(t, self)
+	if outcome := browseLoopback(t, responder, browser); outcome != packetParsed {
+		t.Fatalf("answer outcome = %v, want packetParsed", outcome)
+	}
+	item, ok := share.GetUserScanCurrent(self.Fingerprint)
+	if !ok {
+		t.Fatalf("device %s not in scan-current", self.Fingerprint)
+	}
+	if want := strings.Repeat("ä", (mdnsMaxTXTString-len("alias="))/2); item.Alias != want {
+		t.Errorf("alias = %q (%d bytes), want %d bytes", item.Alias, len(item.Alias), len(want))
+	}
+}
+
+func TestMDNSLoopbackIgnoresSelf(t *testing.T) {
+	self := &types.VersionMessage{Alias: "Self", Fingerprint: "mdns-test-self-fingerprint", Protocol: "http"}
+	t.Cleanup(share.ClearUserScanCurrent)
+
+	responder := startLoopbackResponder(t, self)
+	if outcome := browseLoopback(t, responder, self); outcome != packetIgnored {
+		t.Fatalf("answer outcome = %v, want packetIgnored", outcome)
+	}
+	if _, ok := share.GetUserScanCurrent(self.Fingerprint); ok {
+		t.Fatal("own advertisement was stored in scan-current")
+	}
+}
@@ -0,0 +1,217 @@
+package boardcast
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// networkWatchInterval is how often interfaces and addresses are checked for changes
+const networkWatchInterval = 5 * time.Second
+
+// multicastListener is a running per-interface multicast listener.
+type multicastListener struct {
+	conn      *net.UDPConn
+	signature string // interface state when started; a change restarts the listener
+}
+
+var (
+	// multicastListeners holds the running listeners by interface name ("default" is the system default).
+	multicastListenersMu  sync.Mutex
+	multicastListenersV4  = make(map[string]*multicastListener)
+	multicastListenersV6  = make(map[string]*multicastListener)
+	multicastListenSelf   *types.VersionMessage // set by ListenMulticastUsingUDP; nil while IPv4 listeners are unused
+	multicastListenSelfV6 *types.VersionMessage // set by ListenMulticastUsingUDPv6
+
+	// networkGeneration is bumped on every detected interface or address change.
+	networkGeneration atomic.Uint64
+)
+
+// multicastInterfaceName returns the interface name for logs and listener keys.
+func multicastInterfaceName(iface *net.Interface) string {
+	if iface == nil {
+		return "default"
+	}
+	return iface.Name
+}
+
+// interfaceSignature describes the state of iface that multicast memberships depend on.
+func interfaceSignature(iface *net.Interface) string {
+	if iface == nil {
+		return "default"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s#%d:%s", iface.Name, iface.Index, iface.Flags)
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return sb.String()
+	}
+	list := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		list = append(list, addr.String())
+	}
+	sort.Strings(list)
+	for _, addr := range list {
+		sb.WriteString(";")
+		sb.WriteString(addr)
+	}
+	return sb.String()
+}
+
+// networkSignature describes every interface of the host, used to detect changes.
+func networkSignature() string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	list := make([]string, 0, len(interfaces))
+	for i := range interfaces {
+		list = append(list, interfaceSignature(&interfaces[i]))
+	}
+	sort.Strings(list)
+	return strings.Join(list, "|")
+}
+
+// syncMulticastListeners starts listeners on new interfaces, stops listeners on interfaces that are gone
+// and restarts listeners whose interface changed (e.g. a new DHCP address).
+func syncMulticastListeners() {
+	multicastListenersMu.Lock()
+	defer multicastListenersMu.Unlock()
+
+	if self := multicastListenSelf; self != nil {
+		addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", multcastAddress, multcastPort))
+		if err != nil {
+			tool.DefaultLogger.Errorf("Failed to resolve UDP address: %v", err)
+		} else {
+			interfaces, err := getNetworkInterfaces()
+			if err != nil {
+				tool.DefaultLogger.Warnf("IPv4 multicast discovery is waiting for a network interface: %v", err)
+			}
+			syncListenerSetLocked(multicastListenersV4, discoveryChannelIPv4, interfaces, func(iface *net.Interface) (*net.UDPConn, error) {
+				return listenOnInterface(iface, addr)
+			}, self)
+		}
+	}
+	if self := multicastListenSelfV6; self != nil {
+		addr, err := multicastGroupV6()
+		if err != nil {
+			tool.DefaultLogger.Errorf("IPv6 multicast discovery disabled: %v", err)
+			return
+		}
+		interfaces, err := getNetworkInterfacesV6()
+		if err != nil {
+			tool.DefaultLogger.Warnf("IPv6 multicast discovery is waiting for a network interface: %v", err)
+		}
+		syncListenerSetLocked(multicastListenersV6, discoveryChannelIPv6, interfaces, func(iface *net.Interface) (*net.UDPConn, error) {
+			return listenOnInterfaceV6(iface, addr)
+		}, self)
+	}
+}
+
+// StopMulticastListeners stops every IPv4 and IPv6 multicast listener; the network watcher no longer restarts them.
+func StopMulticastListeners() {
+	multicastListenersMu.Lock()
+	defer multicastListenersMu.Unlock()
+	multicastListenSelf = nil
+	multicastListenSelfV6 = nil
+	for channel, listeners := range map[string]map[string]*multicastListener{
+		discoveryChannelIPv4: multicastListenersV4,
+		discoveryChannelIPv6: multicastListenersV6,
+	} {
+		for name, listener := range listeners {
+			if err := listener.conn.Close(); err != nil {
+				tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
+			}
+			delete(listeners, name)
+			recordLeave(name, channel)
+		}
+	}
+}
+
+// syncListenerSetLocked reconciles the listeners of channel with interfaces. multicastListenersMu must be held.
+func syncListenerSetLocked(listeners map[string]*multicastListener, channel string, interfaces []*net.Interface,
+	open func(*net.Interface) (*net.UDPConn, error), self *types.VersionMessage) {
+	wanted := make(map[string]*net.Interface, len(interfaces))
+	for _, iface := range interfaces {
+		wanted[multicastInterfaceName(iface)] = iface
+	}
+	for name, listener := range listeners {
+		if iface, ok := wanted[name]; ok && interfaceSignature(iface) == listener.signature {
+			continue
+		}
+		if err := listener.conn.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
+		}
+		delete(listeners, name)
+		recordLeave(name, channel)
+	}
+	for name, iface := range wanted {
+		if _, ok := listeners[name]; ok {
+			continue
+		}
+		c, err := open(iface)
+		recordJoin(name, channel, err)
+		if err != nil {
+			// Retried on the next network change.
+			tool.DefaultLogger.Errorf("%v", err)
+			continue
+		}
+		listeners[name] = &multicastListener{conn: c, signature: interfaceSignature(iface)}
+		go serveMulticastConn(c, name, self)
+	}
+}
+
+// StartNetworkWatcher polls the host interfaces every networkWatchInterval. When an interface or address
+// appears or disappears it rebinds the multicast and mDNS listeners, re-initializes the HTTP clients' bind
+// address, invalidates the scan IP cache and sends an immediate announcement. It blocks.
+func StartNetworkWatcher() {
+	last := networkSignature()
+	ticker := time.NewTicker(networkWatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		current := networkSignature()
+		if current == last {
+			continue
+		}
+		last = current
+		tool.DefaultLogger.Info("Network change detected, rebinding discovery")
+		handleNetworkChange()
+	}
+}
+
+// handleNetworkChange applies a detected interface or address change.
+func handleNetworkChange() {
+	networkGeneration.Add(1)
+	invalidateNetworkIPsCache()
+	RebindHTTPClients()
+	syncMulticastListeners()
+	refreshMDNSInterfaces()
+
+	multicastListenersMu.Lock()
+	self := multicastListenSelf
+	if self == nil {
+		self = multicastListenSelfV6
+	}
+	multicastListenersMu.Unlock()
+	if self != nil {
+		if err := SendMulticastOnce(self); err != nil {
+			tool.DefaultLogger.Warnf("Announce after network change failed: %v", err)
+		}
+	}
+	announceMDNS()
+}
+
+// invalidateNetworkIPsCache drops the cached HTTP scan targets so the next scan uses the new networks.
+func invalidateNetworkIPsCache() {
+	networkIPsCacheMu.Lock()
+	defer networkIPsCacheMu.Unlock()
+	networkIPsCache = nil
+	networkIPsCacheKey = ""
+}
@@ -0,0 +1,164 @@
+package boardcast
+
+import (
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	// defaultRegisterResponseWindow is how long a peer we answered is not answered again
+	defaultRegisterResponseWindow = 60 * time.Second
+	// registerResponseJitterMax spreads the answers of many nodes to the same announcement
+	registerResponseJitterMax = 500 * time.Millisecond
+	// registerBackoffBase and registerBackoffMax bound the backoff after a failed HTTP register (doubles per failure)
+	registerBackoffBase = 30 * time.Second
+	registerBackoffMax  = 10 * time.Minute
+	// registerStatePruneInterval is how often idle per-fingerprint states are dropped
+	registerStatePruneInterval = 5 * time.Minute
+)
+
+// registerResponses schedules the /register answers to multicast announcements.
+var registerResponses = &registerScheduler{
+	window: defaultRegisterResponseWindow,
+	peers:  make(map[string]*registerPeerState),
+}
+
+type registerScheduler struct {
+	mu        sync.Mutex
+	window    time.Duration
+	peers     map[string]*registerPeerState
+	lastPrune time.Time
+	stats     types.RegisterResponseStats
+}
+
+type registerPeerState struct {
+	pending  bool      // an answer is scheduled or in flight
+	failures int       // consecutive failed HTTP registers
+	notUntil time.Time // no answer before this time (window after success, backoff after failure)
+}
+
+// SetRegisterResponseWindow sets how long a peer is not answered again after a register.
+// seconds < 0 keeps the default; 0 answers every announcement (answers are still deduplicated and jittered).
+func SetRegisterResponseWindow(seconds int) {
+	if seconds < 0 {
+		return
+	}
+	registerResponses.mu.Lock()
+	defer registerResponses.mu.Unlock()
+	registerResponses.window = time.Duration(seconds) * time.Second
+}
+
+// GetRegisterResponseStats returns the counters of the register response scheduler.
+func GetRegisterResponseStats() types.RegisterResponseStats {
+	s := registerResponses
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.WindowSeconds = int(s.window / time.Second)
+	stats.Tracked = len(s.peers)
+	for _, peer := range s.peers {
+		if peer.pending {
+			stats.Pending++
+		}
+	}
+	return stats
+}
+
+// admit reports whether fingerprint may be answered now and marks the answer as pending.
+func (s *registerScheduler) admit(fingerprint string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	peer, ok := s.peers[fingerprint]
+	if !ok {
+		peer = &registerPeerState{}
+		s.peers[fingerprint] = peer
+	}
+	switch {
+	case peer.pending:
+		s.stats.Deduped++
+		return false
+	case now.Before(peer.notUntil):
+		if peer.failures > 0 {
+			s.stats.BackedOff++
+		} else {
+			s.stats.Throttled++
+		}
+		return false
+	}
+	peer.pending = true
+	s.stats.Scheduled++
+	return true
+}
+
+// finish records the outcome of an answer. httpOK is false when the HTTP register failed, even if the UDP fallback worked.
+func (s *registerScheduler) finish(fingerprint string, httpOK bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peer, ok := s.peers[fingerprint]
+	if !ok {
+		peer = &registerPeerState{}
+		s.peers[fingerprint] = peer
+	}
+	peer.pending = false
+	if httpOK {
+		s.stats.Sent++
+		peer.failures = 0
+		peer.notUntil = now.Add(s.window)
+		return
+	}
+	s.stats.Failed++
+	peer.failures++
+	backoff := registerBackoffMax
+	if shift := peer.failures - 1; shift < 10 {
+		backoff = min(registerBackoffBase<<shift, registerBackoffMax)
+	}
+	peer.notUntil = now.Add(max(backoff, s.window))
+}
+
+// pruneLocked drops idle states whose window or backoff has expired. s.mu must be held.
+func (s *registerScheduler) pruneLocked(now time.Time) {
+	if now.Sub(s.lastPrune) < registerStatePruneInterval {
+		return
+	}
+	s.lastPrune = now
+	for fingerprint, peer := range s.peers {
+		if !peer.pending && now.After(peer.notUntil) {
+			delete(s.peers, fingerprint)
+		}
+	}
+}
+
+// scheduleRegisterResponse answers an announcement with a /register callback after a random delay,
+// unless the peer already has an answer pending, was answered within the window or is backing off.
+func scheduleRegisterResponse(remote types.VersionMessage, remoteAddr *net.UDPAddr, self *types.VersionMessage) {
+	if !registerResponses.admit(remote.Fingerprint, time.Now()) {
+		tool.DefaultLogger.Debugf("Skipping register callback to %s (%s): answered recently or pending", remote.Alias, remote.Fingerprint)
+		return
+	}
+	// Call the /register callback using HTTP/TCP to send the device information to the remote device.
+	// convert self to CallbackVersionMessageHTTP
+	selfHTTP := &types.CallbackVersionMessageHTTP{
+		Alias:       self.Alias,
+		Version:     self.Version,
+		DeviceModel: self.DeviceModel,
+		DeviceType:  self.DeviceType,
+		Fingerprint: self.Fingerprint,
+		Port:        self.Port,
+		Protocol:    self.Protocol,
+		Download:    self.Download,
+	}
+	delay := rand.N(registerResponseJitterMax)
+	time.AfterFunc(delay, func() {
+		usedFallback, callbackErr := callbackRegister(remoteAddr, selfHTTP, &remote)
+		registerResponses.finish(remote.Fingerprint, callbackErr == nil && !usedFallback, time.Now())
+		if callbackErr != nil {
+			tool.DefaultLogger.Errorf("Failed to callback TCP register: %v\n", callbackErr)
+		}
+	})
+}
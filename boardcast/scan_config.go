@@ -35,9 +35,94 @@ func ScanOnceUDP(message *types.VersionMessage) error {
 	return SendMulticastOnce(message)
 }
 
+// StartDiscovery starts the discovery listeners and the auto scan loops of the configured scan mode.
+func StartDiscovery() {
+	config := GetScanConfig()
+	if config == nil {
+		tool.DefaultLogger.Warn("StartDiscovery: scan config not set")
+		return
+	}
+	tool.DefaultLogger.Infof("Using scan mode: %s", config.Mode)
+	startDiscoveryListeners(config)
+	restartAutoScanLoops(config, false)
+}
+
+// startDiscoveryListeners starts the mDNS responder in mDNS mode and the UDP multicast listeners otherwise.
+// The listeners keep running in off mode so the device stays discoverable while it does not scan.
+func startDiscoveryListeners(config *types.ScanConfig) {
+	if config.SelfMessage == nil {
+		return
+	}
+	if IsBroadcastEnabled() {
+		startBroadcastListener(config.SelfMessage)
+	}
+	if config.Mode == types.ScanModeMDNS {
+		go ListenMDNS(config.SelfMessage)
+		return
+	}
+	ListenMulticastUsingUDP(config.SelfMessage)
+	if IsIPv6MulticastEnabled() {
+		ListenMulticastUsingUDPv6(config.SelfMessage)
+	}
+}
+
+// SetScanMode switches the scan mode at runtime: it stops the running auto scan loops, swaps the
+// discovery listeners when switching to or from mDNS and starts the loops of the new mode.
+func SetScanMode(mode types.ScanMode) error {
+	currentScanConfigMu.Lock()
+	if currentScanConfig == nil {
+		currentScanConfigMu.Unlock()
+		return fmt.Errorf("scan config not set")
+	}
+	previous := currentScanConfig.Mode
+	if previous == mode {
+		currentScanConfigMu.Unlock()
+		return nil
+	}
+	updated := *currentScanConfig
+	updated.Mode = mode
+	currentScanConfig = &updated
+	currentScanConfigMu.Unlock()
+
+	tool.DefaultLogger.Infof("Switching scan mode: %s -> %s", previous, mode)
+	StopAutoScan()
+	if previous == types.ScanModeMDNS {
+		StopMDNS()
+		startDiscoveryListeners(&updated)
+	} else if mode == types.ScanModeMDNS {
+		StopMulticastListeners()
+		startDiscoveryListeners(&updated)
+	}
+	restartAutoScanLoops(&updated, false)
+	return nil
+}
+
+// autoScanStopChannelLocked returns the channel closed by the next StopAutoScan. autoScanControlMu must be held.
+func autoScanStopChannelLocked() chan struct{} {
+	if autoScanStopCh == nil {
+		autoScanStopCh = make(chan struct{})
+	}
+	return autoScanStopCh
+}
+
+// StopAutoScan stops every running auto scan loop (UDP announce, HTTP scan, mDNS browse and the scan-now retry loop).
+func StopAutoScan() {
+	autoScanControlMu.Lock()
+	defer autoScanControlMu.Unlock()
+	if autoScanStopCh != nil {
+		close(autoScanStopCh)
+		autoScanStopCh = nil
+	}
+}
+
 // RestartAutoScan sends a restart signal to all running auto scan loops.
 // skipHTTPImmediateScan: if true (e.g. after scan-now), HTTP loop only resets timeout; next scan in 30s.
+// It does nothing in off mode.
 func RestartAutoScan(skipHTTPImmediateScan bool) {
+	if config := GetScanConfig(); config != nil && config.Mode == types.ScanModeOff {
+		tool.DefaultLogger.Debug("Scan mode is off, ignoring auto scan restart")
+		return
+	}
 	autoScanControlMu.Lock()
 	defer autoScanControlMu.Unlock()
 
@@ -70,25 +155,28 @@ func scanNowRestartAutoScan(config *types.ScanConfig) {
 func IsAutoScanRunning() bool {
 	autoScanControlMu.Lock()
 	defer autoScanControlMu.Unlock()
-	return autoScanHTTPRunning || autoScanUDPRunning
+	return autoScanHTTPRunning > 0 || autoScanUDPRunning > 0 || autoScanMDNSRunning > 0
 }
 
-// ScanNow performs scan-now: HTTP scan only (sync), then restarts/resumes normal auto scan in background.
-// - scan-now: executes HTTP scan only; returns after HTTP scan completes so API can return device list.
-// - other/normal scan: unchanged (auto scan by Mode: UDP, HTTP, or Mixed runs in background).
-// When SelfHTTP is nil, falls back to legacy one-shot by Mode.
+// ScanNow performs scan-now by Mode, then restarts/resumes normal auto scan in background.
+// - HTTP and Mixed: executes HTTP scan (sync); returns after HTTP scan completes so API can return device list.
+// - UDP and mDNS: sends one announcement or query; answers arrive in the background.
+// - Off: returns an error, scanning is disabled.
+// When SelfHTTP is nil, HTTP and Mixed fall back to the legacy one-shot.
 // Returns error if scan config is not set or scan fails.
 func ScanNow() error {
 	config := GetScanConfig()
 	if config == nil {
 		return fmt.Errorf("scan config not set")
 	}
+	if config.Mode == types.ScanModeOff {
+		return fmt.Errorf("scanning is disabled (scan mode off)")
+	}
 
-	tool.DefaultLogger.Info("Performing manual scan (HTTP)...")
-
-	if config.SelfHTTP != nil {
+	if config.SelfHTTP != nil && (config.Mode == types.ScanModeHTTP || config.Mode == types.ScanModeMixed) {
+		tool.DefaultLogger.Info("Performing manual scan (HTTP)...")
 		tool.DefaultLogger.Debug("scan-now: executing HTTP scan with default background scan options...")
-		scanNowOpts := &HTTPScanOptions{Concurrency: autoScanConcurrencyLimit, RateLimitPPS: autoScanICMPRatePPS}
+		scanNowOpts := &HTTPScanOptions{Concurrency: autoScanConcurrencyLimit, RateLimitPPS: autoScanICMPRatePPS, MaxTargets: scanNowMaxTargets, sweep: &scanNowSweep}
 
 		// 1. First scan (wait for full completion)
 		if err := ScanOnceHTTP(config.SelfHTTP, scanNowOpts); err != nil {
@@ -106,6 +194,7 @@ func ScanNow() error {
 		return nil
 	}
 
+	tool.DefaultLogger.Infof("Performing manual scan (%s)...", config.Mode)
 	go func() {
 		if IsAutoScanRunning() {
 			tool.DefaultLogger.Debug("Auto scan is running, sending restart signal")
@@ -127,6 +216,10 @@ func ScanNow() error {
 	case types.ScanModeHTTP:
 		return fmt.Errorf("self HTTP message not configured for HTTP scan")
 
+	case types.ScanModeMDNS:
+		tool.DefaultLogger.Debug("Sending mDNS query...")
+		return SendMDNSQueryOnce()
+
 	case types.ScanModeMixed:
 		var udpErr error
 		var wg sync.WaitGroup
@@ -162,6 +255,10 @@ func scanNowBackgroundLoop(config *types.ScanConfig, opts *HTTPScanOptions) {
 	}
 	tool.DefaultLogger.Infof("scan-now: no devices found, starting background retry loop (30s interval, %ds timeout)", httpTimeout)
 
+	autoScanControlMu.Lock()
+	stopCh := autoScanStopChannelLocked()
+	autoScanControlMu.Unlock()
+
 	timeoutTimer := time.NewTimer(time.Duration(httpTimeout) * time.Second)
 	defer timeoutTimer.Stop()
 
@@ -174,6 +271,9 @@ func scanNowBackgroundLoop(config *types.ScanConfig, opts *HTTPScanOptions) {
 			tool.DefaultLogger.Info("scan-now: background retry loop timed out")
 			scanNowRestartAutoScan(config)
 			return
+		case <-stopCh:
+			tool.DefaultLogger.Info("scan-now: background retry loop stopped")
+			return
 		case <-ticker.C:
 			if IsScanPaused() {
 				tool.DefaultLogger.Debug("scan-now: background loop paused, skipping this tick")
@@ -219,5 +319,9 @@ func restartAutoScanLoops(config *types.ScanConfig, skipHTTPInitialScan bool) {
 		if config.SelfHTTP != nil {
 			go ListenMulticastUsingHTTPWithTimeout(config.SelfHTTP, httpTimeout, skipHTTPInitialScan)
 		}
+	case types.ScanModeMDNS:
+		go BrowseMDNSWithTimeout(udpTimeout)
+	case types.ScanModeOff:
+		tool.DefaultLogger.Debug("Scan mode is off, no auto scan loops started")
 	}
 }
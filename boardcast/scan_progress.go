@@ -0,0 +1,90 @@
+package boardcast
+
+import (
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// httpSweep keeps the HTTP scan cursor across auto scan ticks so large target lists
+// (e.g. /21 offices) are covered over several ticks instead of overrunning one.
+var httpSweep httpSweepState
+
+// scanNowSweep is the cursor of scan-now, which probes larger chunks: sharing httpSweep would skip chunks of
+// the auto scan and make scan-now show up in the reported progress.
+var scanNowSweep httpSweepState
+
+type httpSweepState struct {
+	mu       sync.Mutex
+	key      string // network IPs cache key; a change restarts the sweep
+	cursor   int
+	progress types.ScanProgress
+}
+
+// next returns the addresses to probe in this call: all of targets when maxTargets <= 0 or the list
+// fits, otherwise the next maxTargets addresses after the cursor (wrapping around).
+func (s *httpSweepState) next(key string, targets []string, maxTargets int, ratePPS int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key != s.key || s.cursor >= len(targets) {
+		s.key = key
+		s.cursor = 0
+	}
+	if s.cursor == 0 {
+		s.progress.Scanned = 0
+		s.progress.Found = 0
+	}
+	s.progress.Running = true
+	s.progress.Targets = len(targets)
+	s.progress.RatePPS = ratePPS
+	if maxTargets <= 0 || len(targets) <= maxTargets {
+		s.cursor = 0
+		s.progress.Scanned = 0
+		s.progress.Found = 0
+		return targets
+	}
+	end := min(s.cursor+maxTargets, len(targets))
+	return targets[s.cursor:end]
+}
+
+// probed records one finished probe.
+func (s *httpSweepState) probed(found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Scanned++
+	if found {
+		s.progress.Found++
+	}
+}
+
+// done advances the cursor by the size of the finished chunk and closes the sweep when it reaches the end.
+func (s *httpSweepState) done(chunk int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Running = false
+	s.cursor += chunk
+	if s.cursor >= s.progress.Targets {
+		s.cursor = 0
+		s.progress.Sweeps++
+		s.progress.LastSweepEnd = time.Now().UnixMilli()
+	}
+}
+
+// GetHTTPScanProgress returns a snapshot of the HTTP scan sweep progress.
+func GetHTTPScanProgress() types.ScanProgress {
+	httpSweep.mu.Lock()
+	defer httpSweep.mu.Unlock()
+	return httpSweep.progress
+}
+
+// adaptiveScanRate raises basePPS so that n probes fit in autoScanChunkBudget, capped at autoScanMaxRatePPS.
+// basePPS <= 0 means unlimited and is returned unchanged.
+func adaptiveScanRate(n int, basePPS int) int {
+	if basePPS <= 0 {
+		return basePPS
+	}
+	seconds := int(autoScanChunkBudget / time.Second)
+	pps := (n + seconds - 1) / seconds
+	return min(max(pps, basePPS), max(basePPS, autoScanMaxRatePPS))
+}
@@ -0,0 +1,22 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package boardcast
+
+import "syscall"
+
+// broadcastSocketControl enables SO_BROADCAST and shares the discovery port with the multicast listeners.
+// The BSDs need SO_REUSEPORT next to SO_REUSEADDR for a second wildcard bind.
+func broadcastSocketControl(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		for _, opt := range []int{syscall.SO_REUSEADDR, syscall.SO_REUSEPORT, syscall.SO_BROADCAST} {
+			if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, opt, 1); sockErr != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
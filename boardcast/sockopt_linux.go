@@ -0,0 +1,20 @@
+//go:build linux
+
+package boardcast
+
+import "syscall"
+
+// broadcastSocketControl enables SO_BROADCAST and shares the discovery port with the multicast listeners (SO_REUSEADDR).
+func broadcastSocketControl(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); sockErr != nil {
+			return
+		}
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
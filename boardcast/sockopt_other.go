@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package boardcast
+
+import "syscall"
+
+// broadcastSocketControl is a no-op on platforms without known socket options; broadcast sends may be refused.
+func broadcastSocketControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,178 @@
+package boardcast
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	// staticPeersPollInterval is how often configured static peers are polled via /info
+	staticPeersPollInterval = 30 * time.Second
+	// staticPeerResolveTimeout bounds the DNS lookup of a static peer host name
+	staticPeerResolveTimeout = 3 * time.Second
+)
+
+var (
+	// staticPeerStatuses holds the last poll result per static peer, keyed by staticPeerKey.
+	staticPeerStatusesMu sync.RWMutex
+	staticPeerStatuses   = make(map[string]types.StaticPeerStatus)
+)
+
+func staticPeerKey(entry types.StaticPeerEntry) string {
+	return fmt.Sprintf("%s|%d", entry.Host, entry.Port)
+}
+
+// StartStaticPeersPoller polls every configured static peer now and then every staticPeersPollInterval.
+// Reachable peers are merged into scan-current like discovered devices.
+func StartStaticPeersPoller() {
+	PollStaticPeers()
+	ticker := time.NewTicker(staticPeersPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		PollStaticPeers()
+	}
+}
+
+// PollStaticPeers polls all configured static peers concurrently and waits for the results.
+func PollStaticPeers() {
+	var wg sync.WaitGroup
+	for _, entry := range tool.ListStaticPeers() {
+		wg.Add(1)
+		go func(entry types.StaticPeerEntry) {
+			defer wg.Done()
+			PollStaticPeer(entry)
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// PollStaticPeer fetches /info of a single static peer and records its status.
+func PollStaticPeer(entry types.StaticPeerEntry) types.StaticPeerStatus {
+	key := staticPeerKey(entry)
+	staticPeerStatusesMu.RLock()
+	status := staticPeerStatuses[key]
+	staticPeerStatusesMu.RUnlock()
+	status.StaticPeerEntry = entry
+	status.LastChecked = time.Now().UnixMilli()
+
+	item, err := fetchStaticPeer(entry)
+	if err != nil {
+		if status.Online || status.LastError != err.Error() {
+			tool.DefaultLogger.Warnf("[StaticPeers] %s is offline: %v", entry.Host, err)
+		}
+		status.Online = false
+		status.LastError = err.Error()
+	} else {
+		status.Online = true
+		status.LastError = ""
+		status.LastSeen = status.LastChecked
+		status.Device = &item
+		share.SetUserScanCurrent(item.Fingerprint, item)
+	}
+
+	staticPeerStatusesMu.Lock()
+	// Skip the write if the peer was removed while polling.
+	if staticPeerConfigured(entry) {
+		staticPeerStatuses[key] = status
+	}
+	staticPeerStatusesMu.Unlock()
+	return status
+}
+
+// fetchStaticPeer resolves entry.Host and fetches its /info (https first, then http).
+func fetchStaticPeer(entry types.StaticPeerEntry) (types.UserScanCurrentItem, error) {
+	port := entry.Port
+	if port == 0 {
+		port = multcastPort
+	}
+	ip, err := resolveStaticPeerHost(entry.Host)
+	if err != nil {
+		return types.UserScanCurrentItem{}, err
+	}
+	info, protocol, err := transfer.FetchDeviceInfo(ip, port)
+	if err != nil {
+		return types.UserScanCurrentItem{}, err
+	}
+	if info.Fingerprint == "" {
+		return types.UserScanCurrentItem{}, fmt.Errorf("device at %s did not report a fingerprint", entry.Host)
+	}
+	if entry.Fingerprint != "" && info.Fingerprint != entry.Fingerprint {
+		return types.UserScanCurrentItem{}, fmt.Errorf("fingerprint mismatch: expected %s, got %s", entry.Fingerprint, info.Fingerprint)
+	}
+	return types.UserScanCurrentItem{
+		Ipaddress: ip,
+		Source:    types.DeviceSourceStatic,
+		VersionMessage: types.VersionMessage{
+			Alias:       info.Alias,
+			Version:     info.Version,
+			DeviceModel: info.DeviceModel,
+			DeviceType:  info.DeviceType,
+			Fingerprint: info.Fingerprint,
+			Port:        port,
+			Protocol:    protocol,
+			Download:    info.Download,
+			Announce:    true,
+		},
+	}, nil
+}
+
+// resolveStaticPeerHost returns host as an IP string, resolving host names (IPv4 preferred).
+func resolveStaticPeerHost(host string) (string, error) {
+	if ip, err := tool.NormalizeIPString(host); err == nil {
+		return ip, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), staticPeerResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("failed to resolve %s: no addresses", host)
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return addr.IP.String(), nil
+		}
+	}
+	return tool.IPStringWithZone(addrs[0].IP, addrs[0].Zone), nil
+}
+
+func staticPeerConfigured(entry types.StaticPeerEntry) bool {
+	for _, peer := range tool.ListStaticPeers() {
+		if peer.Host == entry.Host && peer.Port == entry.Port {
+			return true
+		}
+	}
+	return false
+}
+
+// ListStaticPeerStatuses returns every configured static peer with its last poll result,
+// including offline and not yet polled peers.
+func ListStaticPeerStatuses() []types.StaticPeerStatus {
+	peers := tool.ListStaticPeers()
+	staticPeerStatusesMu.RLock()
+	defer staticPeerStatusesMu.RUnlock()
+	result := make([]types.StaticPeerStatus, 0, len(peers))
+	for _, entry := range peers {
+		status := staticPeerStatuses[staticPeerKey(entry)]
+		status.StaticPeerEntry = entry
+		result = append(result, status)
+	}
+	return result
+}
+
+// ForgetStaticPeer drops the recorded status of a removed static peer.
+func ForgetStaticPeer(host string, port int) {
+	staticPeerStatusesMu.Lock()
+	defer staticPeerStatusesMu.Unlock()
+	delete(staticPeerStatuses, staticPeerKey(types.StaticPeerEntry{Host: tool.NormalizeStaticPeerHost(host), Port: port}))
+}
@@ -1,8 +1,11 @@
 package boardcast
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -11,102 +14,133 @@ import (
 	"github.com/moyoez/localsend-go/types"
 )
 
-// listenOnInterface listens for multicast messages on a specific network interface. (UDP4)
-func listenOnInterface(iface *net.Interface, addr *net.UDPAddr, self *types.VersionMessage) {
-	interfaceName := iface.Name
+// listenOnInterface opens a multicast listener on a specific network interface (nil = system default). (UDP4)
+// The caller serves it with serveMulticastConn; closing the connection stops the listener.
+func listenOnInterface(iface *net.Interface, addr *net.UDPAddr) (*net.UDPConn, error) {
+	interfaceName := multicastInterfaceName(iface)
 
 	c, err := net.ListenMulticastUDP("udp4", iface, addr)
 	if err != nil {
-		tool.DefaultLogger.Errorf("Failed to listen on multicast UDP address for interface %s: %v", interfaceName, err)
-		return
+		return nil, fmt.Errorf("failed to listen on multicast UDP address for interface %s: %v", interfaceName, err)
 	}
-	defer func() {
-		if err := c.Close(); err != nil {
-			tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
-		}
-	}()
 	err = c.SetReadBuffer(1024 * 8)
 	if err != nil {
 		tool.DefaultLogger.Errorf("Failed to set read buffer: %v", err)
 	}
-	buf := make([]byte, 1024*8)
 	tool.DefaultLogger.Infof("Listening on multicast UDP address: %s (interface: %s)", addr.String(), interfaceName)
+	return c, nil
+}
 
+// serveMulticastConn reads announcements from c until it is closed. Shared by the IPv4 and IPv6 listeners.
+func serveMulticastConn(c *net.UDPConn, interfaceName string, self *types.VersionMessage) {
+	channel := discoveryChannelIPv4
+	if local, ok := c.LocalAddr().(*net.UDPAddr); ok && local.IP.To4() == nil {
+		channel = discoveryChannelIPv6
+	}
+	buf := make([]byte, 1024*8)
 	for {
 		n, addr, err := c.ReadFrom(buf)
 		if err == nil {
-			var incoming types.VersionMessage
-			parseErr := sonic.Unmarshal(buf[:n], &incoming)
-			if parseErr != nil {
-				tool.DefaultLogger.Errorf("Failed to parse UDP message: %v\n", parseErr)
-				continue
-			}
-			// Ignore non-announce or from self broadcasts.
-			if !tool.ShouldRespond(self, &incoming) {
-				continue
-			}
-			tool.DefaultLogger.Debugf("Received %d bytes from %s on interface %s\n", n, addr.String(), interfaceName)
-			tool.DefaultLogger.Debugf("Data: %s\n", string(buf[:n]))
-			udpAddr, castErr := CastToUDPAddr(addr)
-			if castErr != nil {
-				tool.DefaultLogger.Errorf("Unexpected UDP address: %v\n", castErr)
-				continue
+			recordReceived(interfaceName, channel, handleMulticastPacket(buf[:n], addr, interfaceName, channel, self))
+			continue
+		}
+		if errors.Is(err, net.ErrClosed) {
+			tool.DefaultLogger.Infof("Stopped multicast listener on interface %s", interfaceName)
+			return
+		}
+		// error reading from udp, consider using http.
+		tool.DefaultLogger.Errorf("Error reading from UDP on interface %s: %v\n", interfaceName, err)
+	}
+}
+
+// announcementDedupeWindow is how long a copy of the same announcement from the same sender is ignored.
+// One packet reaches every listener bound to the discovery port (one per interface, plus the broadcast listener).
+const announcementDedupeWindow = 2 * time.Second
+
+var (
+	recentAnnouncementsMu sync.Mutex
+	recentAnnouncements   = make(map[uint64]time.Time)
+)
+
+// seenAnnouncementRecently reports whether payload from sender was handled within announcementDedupeWindow
+// and records it otherwise.
+func seenAnnouncementRecently(payload []byte, from net.Addr) bool {
+	h := fnv.New64a()
+	if udpAddr, ok := from.(*net.UDPAddr); ok {
+		_, _ = h.Write(udpAddr.IP)
+	}
+	_, _ = h.Write(payload)
+	key := h.Sum64()
+	now := time.Now()
+
+	recentAnnouncementsMu.Lock()
+	defer recentAnnouncementsMu.Unlock()
+	if seen, ok := recentAnnouncements[key]; ok && now.Sub(seen) < announcementDedupeWindow {
+		return true
+	}
+	if len(recentAnnouncements) >= 256 {
+		for k, seen := range recentAnnouncements {
+			if now.Sub(seen) >= announcementDedupeWindow {
+				delete(recentAnnouncements, k)
 			}
-			share.SetUserScanCurrent(incoming.Fingerprint, types.UserScanCurrentItem{
-				Ipaddress:      udpAddr.IP.String(),
-				VersionMessage: incoming,
-			})
-			go func(remote types.VersionMessage, remoteAddr *net.UDPAddr) {
-				// Call the /register callback using HTTP/TCP to send the device information to the remote device.
-				// convert self to CallbackVersionMessageHTTP
-				selfHTTP := &types.CallbackVersionMessageHTTP{
-					Alias:       self.Alias,
-					Version:     self.Version,
-					DeviceModel: self.DeviceModel,
-					DeviceType:  self.DeviceType,
-					Fingerprint: self.Fingerprint,
-					Port:        self.Port,
-					Protocol:    self.Protocol,
-					Download:    self.Download,
-				}
-				if callbackErr := CallbackMulticastMessageUsingTCP(remoteAddr, selfHTTP, &remote); callbackErr != nil {
-					tool.DefaultLogger.Errorf("Failed to callback TCP register: %v\n", callbackErr)
-				}
-			}(incoming, udpAddr)
-		} else {
-			// error reading from udp, consider using http.
-			tool.DefaultLogger.Errorf("Error reading from UDP on interface %s: %v\n", interfaceName, err)
 		}
 	}
+	recentAnnouncements[key] = now
+	return false
+}
+
+// handleMulticastPacket parses an announcement received on interfaceName, records the sender in
+// scan-current and answers it through the /register callback. Shared by the IPv4, IPv6 and broadcast listeners.
+func handleMulticastPacket(payload []byte, from net.Addr, interfaceName, channel string, self *types.VersionMessage) packetOutcome {
+	if matchSelfTest(payload, interfaceName, channel) || seenAnnouncementRecently(payload, from) {
+		return packetIgnored
+	}
+	var incoming types.VersionMessage
+	parseErr := sonic.Unmarshal(payload, &incoming)
+	if parseErr != nil {
+		tool.DefaultLogger.Errorf("Failed to parse UDP message: %v\n", parseErr)
+		return packetInvalid
+	}
+	// Ignore non-announce or from self broadcasts.
+	if !tool.ShouldRespond(self, &incoming) {
+		return packetIgnored
+	}
+	tool.DefaultLogger.Debugf("Received %d bytes from %s on interface %s\n", len(payload), from.String(), interfaceName)
+	tool.DefaultLogger.Debugf("Data: %s\n", string(payload))
+	udpAddr, castErr := CastToUDPAddr(from)
+	if castErr != nil {
+		tool.DefaultLogger.Errorf("Unexpected UDP address: %v\n", castErr)
+		return packetInvalid
+	}
+	ipaddress := tool.IPStringWithZone(udpAddr.IP, udpAddr.Zone)
+	if udpAddr.IP.To4() == nil {
+		// Mixed mode: keep an already known IPv4 address instead of flapping between address families.
+		if existing, ok := share.GetUserScanCurrent(incoming.Fingerprint); ok && net.ParseIP(existing.Ipaddress).To4() != nil {
+			ipaddress = existing.Ipaddress
+		}
+	}
+	share.SetUserScanCurrent(incoming.Fingerprint, types.UserScanCurrentItem{
+		Ipaddress:      ipaddress,
+		Source:         types.DeviceSourceUDP,
+		VersionMessage: incoming,
+	})
+	scheduleRegisterResponse(incoming, udpAddr, self)
+	return packetParsed
 }
 
 // ListenMulticastUsingUDP listens for multicast UDP broadcasts to discover other devices.
 // Only respond to callbacks if the remote device announce=true and is not the same device.
 // * With Register Callback
 // * With Prepare-upload Callback
+// One listener runs per interface; StartNetworkWatcher starts and stops them as interfaces come and go.
 func ListenMulticastUsingUDP(self *types.VersionMessage) {
-	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", multcastAddress, multcastPort))
-	if err != nil {
+	if _, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", multcastAddress, multcastPort)); err != nil {
 		tool.DefaultLogger.Fatalf("Failed to resolve UDP address: %v", err)
 	}
-
-	interfaces, err := getNetworkInterfaces()
-	if err != nil {
-		tool.DefaultLogger.Fatalf("Failed to get network interfaces: %v", err)
-	}
-
-	if len(interfaces) == 1 {
-		// single interface, just run
-		listenOnInterface(interfaces[0], addr, self)
-	} else {
-		// multiple interfaces, start a goroutine for each interface
-		tool.DefaultLogger.Infof("Listening on %d network interfaces", len(interfaces))
-		for _, iface := range interfaces {
-			go listenOnInterface(iface, addr, self)
-		}
-		// block the main goroutine
-		select {}
-	}
+	multicastListenersMu.Lock()
+	multicastListenSelf = self
+	multicastListenersMu.Unlock()
+	syncMulticastListeners()
 }
 
 // timeout: total duration in seconds after which sending stops. 0 means no timeout.
@@ -122,16 +156,17 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 
 	// Register UDP scan as running and get restart channel
 	autoScanControlMu.Lock()
-	autoScanUDPRunning = true
+	autoScanUDPRunning++
 	if autoScanRestartCh == nil {
 		autoScanRestartCh = make(chan restartAction, 1)
 	}
 	restartCh := autoScanRestartCh
+	stopCh := autoScanStopChannelLocked()
 	autoScanControlMu.Unlock()
 
 	defer func() {
 		autoScanControlMu.Lock()
-		autoScanUDPRunning = false
+		autoScanUDPRunning--
 		autoScanControlMu.Unlock()
 	}()
 
@@ -142,7 +177,9 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 	}
 
 	var c *net.UDPConn
+	var dialedGeneration uint64
 	dialConn := func() error {
+		generation := networkGeneration.Load()
 		conn, dialErr := net.DialUDP("udp4", nil, addr)
 		if dialErr != nil {
 			return dialErr
@@ -151,6 +188,7 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 			_ = c.Close()
 		}
 		c = conn
+		dialedGeneration = generation
 		return nil
 	}
 	if err := dialConn(); err != nil {
@@ -194,6 +232,11 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 
 	// Send immediately first
 	sendOnce := func() {
+		if c != nil && dialedGeneration != networkGeneration.Load() {
+			// Interfaces or addresses changed since the dial; the socket may still use a stale source address.
+			_ = c.Close()
+			c = nil
+		}
 		if c == nil {
 			if err := dialConn(); err != nil {
 				tool.DefaultLogger.Errorf("failed to dial UDP address: %v", err)
@@ -206,6 +249,7 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 			return
 		}
 		_, err = c.Write(payload)
+		recordSent("default", discoveryChannelIPv4, err)
 		if err != nil {
 			if tool.ShouldRedialUDP(err) {
 				tool.DefaultLogger.Warnf("IP/network unavailable (e.g. after network change), will redial on next send: %v", err)
@@ -215,6 +259,16 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 				tool.DefaultLogger.Errorf("failed to write message: %v", err)
 			}
 		}
+		if IsIPv6MulticastEnabled() {
+			if err := sendMulticastPayloadV6(payload); err != nil {
+				tool.DefaultLogger.Warnf("failed to send IPv6 multicast message: %v", err)
+			}
+		}
+		if IsBroadcastEnabled() {
+			if err := sendBroadcastPayload(payload); err != nil {
+				tool.DefaultLogger.Warnf("failed to send broadcast message: %v", err)
+			}
+		}
 	}
 
 	// Initial send
@@ -227,6 +281,9 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 			elapsed := time.Since(startTime)
 			tool.DefaultLogger.Infof("UDP multicast sending stopped after timeout (%v elapsed)", elapsed.Round(time.Second))
 			return
+		case <-stopCh:
+			tool.DefaultLogger.Info("UDP multicast sending stopped")
+			return
 		case <-restartCh:
 			// Restart signal received, reset timeout and continue sending
 			resetTimeout()
@@ -248,10 +305,31 @@ func SendMulticastOnce(message *types.VersionMessage) error {
 		tool.DefaultLogger.Errorf("Missing message")
 		return nil
 	}
+	payload, err := sonic.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	if err := sendMulticastPayload(payload); err != nil {
+		return err
+	}
+	if IsIPv6MulticastEnabled() {
+		if err := sendMulticastPayloadV6(payload); err != nil {
+			tool.DefaultLogger.Warnf("failed to send IPv6 multicast message: %v", err)
+		}
+	}
+	if IsBroadcastEnabled() {
+		if err := sendBroadcastPayload(payload); err != nil {
+			tool.DefaultLogger.Warnf("failed to send broadcast message: %v", err)
+		}
+	}
+	return nil
+}
+
+// sendMulticastPayload writes payload once to the IPv4 multicast group through the default route.
+func sendMulticastPayload(payload []byte) error {
 	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", multcastAddress, multcastPort))
 	if err != nil {
-		tool.DefaultLogger.Errorf("Failed to resolve UDP address: %v", err)
-		return nil
+		return fmt.Errorf("failed to resolve UDP address: %v", err)
 	}
 	c, err := net.DialUDP("udp4", nil, addr)
 	if err != nil {
@@ -265,11 +343,9 @@ func SendMulticastOnce(message *types.VersionMessage) error {
 			tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
 		}
 	}()
-	payload, err := sonic.Marshal(message)
+	_, err = c.Write(payload)
+	recordSent("default", discoveryChannelIPv4, err)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message: %v", err)
-	}
-	if _, err := c.Write(payload); err != nil {
 		if tool.IsAddrNotAvailableError(err) {
 			return fmt.Errorf("IP address not available, please check your network environment and try again: %w", err)
 		}
@@ -304,6 +380,7 @@ func CallbackMulticastMessageUsingUDP(message *types.VersionMessage) error {
 		return fmt.Errorf("failed to marshal message: %v", err)
 	}
 	_, err = c.Write(payload)
+	recordSent("default", discoveryChannelIPv4, err)
 	if err != nil {
 		if tool.IsAddrNotAvailableError(err) {
 			return fmt.Errorf("IP address not available, please check your network environment and try again: %w", err)
@@ -311,5 +388,10 @@ func CallbackMulticastMessageUsingUDP(message *types.VersionMessage) error {
 		return fmt.Errorf("failed to write message: %v", err)
 	}
 	tool.DefaultLogger.Debugf("Sent UDP multicast message to %s", addr.String())
+	if IsBroadcastEnabled() {
+		if err := sendBroadcastPayload(payload); err != nil {
+			tool.DefaultLogger.Warnf("failed to send broadcast response: %v", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,109 @@
+package boardcast
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// multicastGroupV6 resolves the IPv6 multicast group and port.
+func multicastGroupV6() (*net.UDPAddr, error) {
+	ip := net.ParseIP(multcastAddressV6)
+	if ip == nil || ip.To4() != nil || !ip.IsMulticast() {
+		return nil, fmt.Errorf("invalid IPv6 multicast address: %s", multcastAddressV6)
+	}
+	return &net.UDPAddr{IP: ip, Port: multcastPort}, nil
+}
+
+// listenOnInterfaceV6 opens a multicast listener on a specific network interface. (UDP6)
+// The caller serves it with serveMulticastConn; closing the connection stops the listener.
+func listenOnInterfaceV6(iface *net.Interface, addr *net.UDPAddr) (*net.UDPConn, error) {
+	interfaceName := iface.Name
+
+	c, err := net.ListenMulticastUDP("udp6", iface, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on IPv6 multicast UDP address for interface %s: %v", interfaceName, err)
+	}
+	err = c.SetReadBuffer(1024 * 8)
+	if err != nil {
+		tool.DefaultLogger.Errorf("Failed to set read buffer: %v", err)
+	}
+	tool.DefaultLogger.Infof("Listening on IPv6 multicast UDP address: [%s]:%d (interface: %s)", addr.IP.String(), addr.Port, interfaceName)
+	return c, nil
+}
+
+// ListenMulticastUsingUDPv6 listens on the IPv6 multicast group on every interface enabled by
+// SetIPv6MulticastInterfaces. Packets are handled exactly like IPv4 announcements.
+// Like ListenMulticastUsingUDP, the per-interface listeners follow interface changes.
+func ListenMulticastUsingUDPv6(self *types.VersionMessage) {
+	if _, err := multicastGroupV6(); err != nil {
+		tool.DefaultLogger.Errorf("IPv6 multicast discovery disabled: %v", err)
+		return
+	}
+	multicastListenersMu.Lock()
+	multicastListenSelfV6 = self
+	multicastListenersMu.Unlock()
+	syncMulticastListeners()
+}
+
+// sendMulticastPayloadV6 writes payload to the IPv6 multicast group once per enabled interface.
+// A link-local group needs an explicit zone, so a single dialed socket is not enough here.
+func sendMulticastPayloadV6(payload []byte) error {
+	group, err := multicastGroupV6()
+	if err != nil {
+		return err
+	}
+	interfaces, err := getNetworkInterfacesV6()
+	if err != nil {
+		return err
+	}
+	c, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6unspecified})
+	if err != nil {
+		return fmt.Errorf("failed to open UDP6 socket: %v", err)
+	}
+	defer func() {
+		if err := c.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close IPv6 multicast UDP connection: %v", err)
+		}
+	}()
+	var errs []error
+	for _, iface := range interfaces {
+		target := &net.UDPAddr{IP: group.IP, Port: group.Port, Zone: iface.Name}
+		_, err := c.WriteToUDP(payload, target)
+		recordSent(iface.Name, discoveryChannelIPv6, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("interface %s: %w", iface.Name, err))
+			continue
+		}
+		tool.DefaultLogger.Debugf("Sent UDP6 multicast message to %s", target.String())
+	}
+	return errors.Join(errs...)
+}
+
+// SendMulticastOnceV6 sends a single announcement to the IPv6 multicast group.
+func SendMulticastOnceV6(message *types.VersionMessage) error {
+	if message == nil {
+		return fmt.Errorf("missing message")
+	}
+	payload, err := sonic.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return sendMulticastPayloadV6(payload)
+}
+
+// CallbackMulticastMessageUsingUDPv6 is the IPv6 counterpart of CallbackMulticastMessageUsingUDP,
+// used when the /register callback to an IPv6 peer fails.
+func CallbackMulticastMessageUsingUDPv6(message *types.VersionMessage) error {
+	if message == nil {
+		return fmt.Errorf("missing response message")
+	}
+	response := *message
+	// The UDP response needs to explicitly mark announce=false to avoid triggering a callback from the remote device.
+	response.Announce = false
+	return SendMulticastOnceV6(&response)
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"time"
+
 	"github.com/charmbracelet/log"
 	"github.com/moyoez/localsend-go/api"
+	"github.com/moyoez/localsend-go/api/models"
 	"github.com/moyoez/localsend-go/boardcast"
 	"github.com/moyoez/localsend-go/notify"
+	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
-	"github.com/moyoez/localsend-go/types"
 )
 
 func main() {
@@ -17,6 +20,9 @@ func main() {
 		tool.DefaultLogger.Fatalf("%v", err)
 	}
 	tool.InitLogger()
+	if err := share.LoadKnownDevices(""); err != nil {
+		tool.DefaultLogger.Errorf("%v", err)
+	}
 
 	// set user self action.
 	message, httpMessage := tool.BuildVersionMessages(&appCfg, FlagConfig)
@@ -39,18 +45,50 @@ func main() {
 	// sets here.
 	boardcast.SetMultcastAddress(FlagConfig.UseMultcastAddress)
 	boardcast.SetMultcastPort(FlagConfig.UseMultcastPort)
+	boardcast.SetMultcastAddressV6(FlagConfig.UseMultcastAddressV6)
+	boardcast.SetIPv6MulticastInterfaces(FlagConfig.UseIPv6Multicast)
+	boardcast.SetBroadcastInterfaces(FlagConfig.UseBroadcast)
 	boardcast.SetReferNetworkInterface(FlagConfig.UseReferNetworkInterface)
-	if bindAddr, err := boardcast.GetPreferredOutgoingBindAddr(); err != nil {
-		tool.DefaultLogger.Warnf("GetPreferredOutgoingBindAddr: %v, HTTP clients will use default interface", err)
-		tool.InitHTTPClients(nil)
-	} else {
-		tool.InitHTTPClients(bindAddr)
+	boardcast.SetRegisterResponseWindow(FlagConfig.RegisterResponseWindow)
+	if err := tool.InitProbeStrategy(FlagConfig.ProbeStrategy); err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
+	}
+	if err := tool.SetReceivePolicy(appCfg.ReceivePolicy); err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
+	}
+	if err := tool.SetReceiveRouting(appCfg.ReceiveRouting); err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
+	}
+	if err := tool.SetReceiveQuota(appCfg.ReceiveQuota); err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
+	}
+	if err := models.SetInboundSessionLimits(appCfg.InboundSessions); err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
+	}
+	if err := tool.SetBandwidthLimits(appCfg.Bandwidth); err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
+	}
+	if err := notify.SetReceiveHooks(appCfg.ReceiveHooks); err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
+	}
+	scanMode, err := tool.ResolveScanMode(FlagConfig, appCfg)
+	if err != nil {
+		tool.DefaultLogger.Fatalf("%v", err)
 	}
+	boardcast.RebindHTTPClients()
 	api.SetDefaultUploadFolder(FlagConfig.UseDefaultUploadFolder)
 	api.SetDoNotMakeSessionFolder(FlagConfig.DoNotMakeSessionFolder)
 	tool.SetProgramConfigStatus(FlagConfig.UsePin, FlagConfig.UseAutoSave, FlagConfig.UseAutoSaveFromFavorites)
 	api.SetDefaultWebOutPath(FlagConfig.UseWebOutPath)
 	notify.SetUseNotify(!FlagConfig.SkipNotify)
+	tool.SetInsecureSkipPeerVerify(FlagConfig.InsecureSkipPeerVerify)
+	tool.SetPeerCertificateErrorHandler(func(certErr *tool.PeerCertificateError) {
+		go func() {
+			if err := notify.SendPeerCertificateNotification(certErr); err != nil {
+				tool.DefaultLogger.Debugf("Failed to send peer certificate notification: %v", err)
+			}
+		}()
+	})
 
 	// armed, clear this area. // port should focus on 53317
 	apiServer := api.NewServerWithConfig(53317, message.Protocol, FlagConfig.UseConfigPath)
@@ -61,12 +99,13 @@ func main() {
 		}
 	}()
 
-	// Default: mixed scan (UDP + HTTP)
-	tool.DefaultLogger.Info("Using Mixed Scan Mode: UDP and HTTP scanning")
-	boardcast.SetScanConfig(types.ScanModeMixed, message, httpMessage, FlagConfig.ScanTimeout, 60)
-	go boardcast.ListenMulticastUsingUDP(message)
-	go boardcast.SendMulticastUsingUDPWithTimeout(message, FlagConfig.ScanTimeout)
-	go boardcast.ListenMulticastUsingHTTPWithTimeout(httpMessage, 60, false)
+	boardcast.SetScanConfig(scanMode, message, httpMessage, FlagConfig.ScanTimeout, 60)
+	boardcast.StartDiscovery()
+	go boardcast.StartNetworkWatcher()
+	go boardcast.StartDeviceHeartbeat(time.Duration(FlagConfig.HeartbeatInterval) * time.Second)
+	go boardcast.StartStaticPeersPoller()
+	go models.StartPartFileJanitor(10 * time.Minute)
+	go models.StartInboundSessionSweeper(time.Minute)
 
 	select {}
 }
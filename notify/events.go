@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// EventHistorySize is the number of recent notifications kept so event stream clients can resume.
+// upload_progress notifications are not kept: they are frequent and superseded by the next one, and would push
+// everything else out of history.
+const EventHistorySize = 256
+
+// eventSubscriberBuffer is the per-subscriber queue length. A subscriber that falls further behind is
+// disconnected and catches up from history on reconnect (Last-Event-ID).
+const eventSubscriberBuffer = 64
+
+// Event is a serialized notification tagged with a monotonically increasing id.
+type Event struct {
+	ID      uint64
+	Type    string
+	Payload []byte // JSON-encoded types.Notification
+}
+
+var events = &eventHub{subscribers: make(map[chan Event]struct{})}
+
+// eventHub fans notifications out to in-process subscribers and keeps a ring buffer for resume.
+type eventHub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event // ring buffer, oldest at head once full
+	head        int
+	subscribers map[chan Event]struct{}
+}
+
+// publishEvent records notification in history, unless it is upload_progress, and forwards it to every subscriber.
+func publishEvent(notification *types.Notification) {
+	if notification == nil {
+		return
+	}
+	payload, err := sonic.Marshal(notification)
+	if err != nil {
+		tool.DefaultLogger.Errorf("[Events] Failed to serialize notification: %v", err)
+		return
+	}
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	events.lastID++
+	event := Event{ID: events.lastID, Type: notification.Type, Payload: payload}
+	switch {
+	case notification.Type == types.NotifyTypeUploadProgress:
+	case len(events.history) < EventHistorySize:
+		events.history = append(events.history, event)
+	default:
+		events.history[events.head] = event
+		events.head = (events.head + 1) % EventHistorySize
+	}
+	for ch := range events.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Too slow: drop the subscriber instead of blocking notifications.
+			delete(events.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SubscribeEvents registers a new event subscriber. With replay set it returns the events newer than
+// lastID that are still in history. It also returns a channel for live events (closed when the
+// subscriber is dropped) and a cancel func. Backlog and channel never overlap or miss an event.
+func SubscribeEvents(lastID uint64, replay bool) ([]Event, <-chan Event, func()) {
+	ch := make(chan Event, eventSubscriberBuffer)
+
+	events.mu.Lock()
+	var backlog []Event
+	if replay {
+		n := len(events.history)
+		for i := range n {
+			event := events.history[(events.head+i)%n]
+			if event.ID > lastID {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+	events.subscribers[ch] = struct{}{}
+	events.mu.Unlock()
+
+	cancel := func() {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+		if _, ok := events.subscribers[ch]; ok {
+			delete(events.subscribers, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, cancel
+}
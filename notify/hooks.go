@@ -0,0 +1,281 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	defaultHookConcurrency = 2
+	defaultHookTimeout     = 60 * time.Second
+	// maxQueuedHooks bounds the hook runs waiting for a slot; further runs are skipped with a warning.
+	maxQueuedHooks = 64
+	// hookOutputLimit is how much command output or webhook response is kept for the log.
+	hookOutputLimit = 4 << 10
+	// hookEnvTextLimit caps LOCALSEND_TEXT so a long message cannot exceed the environment size limit (E2BIG);
+	// the full text is in the JSON payload on stdin.
+	hookEnvTextLimit = 4 << 10
+)
+
+var receiveHookEvents = []string{types.ReceiveHookSessionCompleted, types.ReceiveHookFileReceived, types.ReceiveHookTextReceived}
+
+// receiveHook is a validated ReceiveHook.
+type receiveHook struct {
+	types.ReceiveHook
+	name    string
+	timeout time.Duration
+}
+
+var (
+	receiveHooksMu sync.RWMutex
+	receiveHooks   []receiveHook
+	hookSlots      = make(chan struct{}, defaultHookConcurrency)
+	queuedHooks    atomic.Int32
+	// hookHTTPClient does not follow redirects, so a webhook cannot bounce the payload to an unchecked URL.
+	hookHTTPClient = &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// SetReceiveHooks validates cfg and makes its hooks active. On error the hooks are unchanged.
+func SetReceiveHooks(cfg types.ReceiveHooksConfig) error {
+	if cfg.MaxConcurrent < 0 {
+		return fmt.Errorf("receive hooks: maxConcurrent must not be negative")
+	}
+	hooks := make([]receiveHook, 0, len(cfg.Hooks))
+	for i, hook := range cfg.Hooks {
+		h := receiveHook{ReceiveHook: hook, name: hook.Name, timeout: defaultHookTimeout}
+		if h.name == "" {
+			h.name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(hook.Events) == 0 {
+			return fmt.Errorf("receive hook %s: no events (use %s)", h.name, strings.Join(receiveHookEvents, ", "))
+		}
+		for _, event := range hook.Events {
+			if !slices.Contains(receiveHookEvents, event) {
+				return fmt.Errorf("receive hook %s: unknown event %q (use %s)", h.name, event, strings.Join(receiveHookEvents, ", "))
+			}
+		}
+		if (len(hook.Command) > 0) == (hook.Webhook != "") {
+			return fmt.Errorf("receive hook %s: set exactly one of command and webhook", h.name)
+		}
+		if len(hook.Command) > 0 && strings.TrimSpace(hook.Command[0]) == "" {
+			return fmt.Errorf("receive hook %s: empty command", h.name)
+		}
+		if hook.Webhook != "" {
+			if err := validateHookWebhook(hook.Webhook); err != nil {
+				return fmt.Errorf("receive hook %s: %v", h.name, err)
+			}
+		}
+		if hook.TimeoutSeconds < 0 {
+			return fmt.Errorf("receive hook %s: timeoutSeconds must not be negative", h.name)
+		}
+		if hook.TimeoutSeconds > 0 {
+			h.timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+		}
+		hooks = append(hooks, h)
+	}
+	concurrency := cfg.MaxConcurrent
+	if concurrency == 0 {
+		concurrency = defaultHookConcurrency
+	}
+	receiveHooksMu.Lock()
+	receiveHooks = hooks
+	hookSlots = make(chan struct{}, concurrency)
+	receiveHooksMu.Unlock()
+	if len(hooks) > 0 {
+		tool.DefaultLogger.Infof("Receive hooks: %d hooks loaded, %d run at once", len(hooks), concurrency)
+	}
+	return nil
+}
+
+// validateHookWebhook accepts http(s) URLs on localhost or a loopback address only, so received data is never
+// posted to another machine by a hook.
+func validateHookWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q (use http:// or https://)", webhook)
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && addr.Unmap().IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("webhook %q must point to localhost or a loopback address", webhook)
+}
+
+// RunReceiveHooks starts the hooks configured for payload.Event in the background and returns immediately.
+func RunReceiveHooks(payload types.ReceiveHookPayload) {
+	receiveHooksMu.RLock()
+	hooks, slots := receiveHooks, hookSlots
+	receiveHooksMu.RUnlock()
+	if payload.Timestamp == 0 {
+		payload.Timestamp = time.Now().Unix()
+	}
+	for _, hook := range hooks {
+		if !slices.Contains(hook.Events, payload.Event) {
+			continue
+		}
+		if queuedHooks.Add(1) > maxQueuedHooks {
+			queuedHooks.Add(-1)
+			tool.DefaultLogger.Warnf("Receive hook %s skipped for %s (session %s): too many hooks queued", hook.name, payload.Event, payload.SessionId)
+			continue
+		}
+		go func(hook receiveHook) {
+			defer queuedHooks.Add(-1)
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			runReceiveHook(hook, payload)
+		}(hook)
+	}
+}
+
+func runReceiveHook(hook receiveHook, payload types.ReceiveHookPayload) {
+	body, err := sonic.Marshal(payload)
+	if err != nil {
+		tool.DefaultLogger.Errorf("Receive hook %s: failed to serialize payload: %v", hook.name, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+	defer cancel()
+	started := time.Now()
+	if len(hook.Command) > 0 {
+		runHookCommand(ctx, hook, payload, body, started)
+		return
+	}
+	runHookWebhook(ctx, hook, payload, body, started)
+}
+
+func runHookCommand(ctx context.Context, hook receiveHook, payload types.ReceiveHookPayload, body []byte, started time.Time) {
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Dir = hook.WorkDir
+	cmd.Env = append(os.Environ(), receiveHookEnv(payload)...)
+	cmd.Stdin = bytes.NewReader(body)
+	output := &limitedBuffer{limit: hookOutputLimit}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 5 * time.Second // do not hang on children that keep the output open
+
+	err := cmd.Run()
+	elapsed := time.Since(started).Round(time.Millisecond)
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		tool.DefaultLogger.Infof("Receive hook %s (%s, session %s): exited with status 0 in %v", hook.name, payload.Event, payload.SessionId, elapsed)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		tool.DefaultLogger.Warnf("Receive hook %s (%s, session %s): killed after timeout of %v", hook.name, payload.Event, payload.SessionId, hook.timeout)
+	case errors.As(err, &exitErr):
+		tool.DefaultLogger.Warnf("Receive hook %s (%s, session %s): exited with status %d in %v: %s",
+			hook.name, payload.Event, payload.SessionId, exitErr.ExitCode(), elapsed, strings.TrimSpace(output.String()))
+		return
+	default:
+		tool.DefaultLogger.Errorf("Receive hook %s (%s, session %s): failed to run %s: %v", hook.name, payload.Event, payload.SessionId, hook.Command[0], err)
+		return
+	}
+	if output.Len() > 0 {
+		tool.DefaultLogger.Debugf("Receive hook %s output: %s", hook.name, strings.TrimSpace(output.String()))
+	}
+}
+
+func runHookWebhook(ctx context.Context, hook receiveHook, payload types.ReceiveHookPayload, body []byte, started time.Time) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Webhook, bytes.NewReader(body))
+	if err != nil {
+		tool.DefaultLogger.Errorf("Receive hook %s: failed to create webhook request: %v", hook.name, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hookHTTPClient.Do(req)
+	if err != nil {
+		tool.DefaultLogger.Warnf("Receive hook %s (%s, session %s): webhook failed: %v", hook.name, payload.Event, payload.SessionId, err)
+		return
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close webhook response body: %v", err)
+		}
+	}()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, hookOutputLimit))
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		tool.DefaultLogger.Warnf("Receive hook %s (%s, session %s): webhook answered %s in %v: %s",
+			hook.name, payload.Event, payload.SessionId, resp.Status, elapsed, strings.TrimSpace(string(response)))
+		return
+	}
+	tool.DefaultLogger.Infof("Receive hook %s (%s, session %s): webhook answered %s in %v", hook.name, payload.Event, payload.SessionId, resp.Status, elapsed)
+}
+
+// receiveHookEnv returns payload as LOCALSEND_* environment variables. LOCALSEND_SAVE_PATHS joins the paths
+// with the OS path list separator. LOCALSEND_TEXT holds at most hookEnvTextLimit bytes of the text;
+// LOCALSEND_TEXT_TRUNCATED is 1 when it was cut.
+func receiveHookEnv(payload types.ReceiveHookPayload) []string {
+	savePaths := make([]string, 0, len(payload.SavePaths))
+	for _, path := range payload.SavePaths {
+		savePaths = append(savePaths, path)
+	}
+	sort.Strings(savePaths)
+	text, truncated := payload.Text, "0"
+	if len(text) > hookEnvTextLimit {
+		text = strings.ToValidUTF8(text[:hookEnvTextLimit], "")
+		truncated = "1"
+	}
+	vars := [][2]string{
+		{"LOCALSEND_EVENT", payload.Event},
+		{"LOCALSEND_SESSION_ID", payload.SessionId},
+		{"LOCALSEND_SENDER_ALIAS", payload.SenderAlias},
+		{"LOCALSEND_SENDER_FINGERPRINT", payload.SenderFingerprint},
+		{"LOCALSEND_SENDER_IP", payload.SenderIP},
+		{"LOCALSEND_FILE_ID", payload.FileId},
+		{"LOCALSEND_FILE_NAME", payload.FileName},
+		{"LOCALSEND_FILE_TYPE", payload.FileType},
+		{"LOCALSEND_FILE_SIZE", strconv.FormatInt(payload.Size, 10)},
+		{"LOCALSEND_SAVE_PATH", payload.SavePath},
+		{"LOCALSEND_SAVE_PATHS", strings.Join(savePaths, string(os.PathListSeparator))},
+		{"LOCALSEND_TOTAL_FILES", strconv.Itoa(payload.TotalFiles)},
+		{"LOCALSEND_SUCCESS_FILES", strconv.Itoa(payload.SuccessFiles)},
+		{"LOCALSEND_FAILED_FILES", strconv.Itoa(payload.FailedFiles)},
+		{"LOCALSEND_TEXT", text},
+		{"LOCALSEND_TEXT_TRUNCATED", truncated},
+	}
+	env := make([]string, 0, len(vars))
+	for _, v := range vars {
+		// NUL cannot be passed in the environment; names and paths come from the sender.
+		env = append(env, v[0]+"="+strings.ReplaceAll(v[1], "\x00", ""))
+	}
+	return env
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
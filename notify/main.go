@@ -52,14 +52,9 @@ func SetUseNotify(use bool) {
 }
 
 // SendNotification sends notification via Unix Domain Socket
+// Every notification is also published to the event stream (GET /api/self/v1/events), even when
+// the Unix socket is disabled.
 func SendNotification(notification *types.Notification, socketPath string) error {
-	if !UseNotify {
-		return nil
-	}
-	if socketPath == "" {
-		socketPath = DefaultUnixSocketPath
-	}
-
 	// Truncate files for confirm_recv / confirm_download (prepare_upload flow)
 	if notification != nil && notification.Data != nil &&
 		(notification.Type == types.NotifyTypeConfirmRecv || notification.Type == types.NotifyTypeConfirmDownload) {
@@ -68,6 +63,14 @@ func SendNotification(notification *types.Notification, socketPath string) error
 			notification.Data["totalFiles"] = len(files)
 		}
 	}
+	publishEvent(notification)
+
+	if !UseNotify {
+		return nil
+	}
+	if socketPath == "" {
+		socketPath = DefaultUnixSocketPath
+	}
 
 	// Check if socket file exists
 	if _, err := os.Stat(socketPath); os.IsNotExist(err) {
@@ -296,6 +299,9 @@ func SendUploadNotification(eventType, sessionId, fileId string, fileInfo map[st
 			notification.Title = "Upload Completed"
 			notification.Message = fmt.Sprintf("File upload completed: sessionId=%s, fileId=%s", sessionId, fileId)
 		}
+	case types.NotifyTypeUploadProgress:
+		notification.Title = "Upload Progress"
+		notification.Message = fmt.Sprintf("Upload in progress: sessionId=%s, fileId=%s", sessionId, fileId)
 	default:
 		notification.Title = "Upload Event"
 		notification.Message = fmt.Sprintf("Upload event: %s, sessionId=%s, fileId=%s", eventType, sessionId, fileId)
@@ -332,6 +338,23 @@ func SendTextReceivedNotification(from, title, content, fileName, sessionId stri
 	return SendNotification(notification, DefaultUnixSocketPath)
 }
 
+// SendPeerCertificateNotification warns that a peer presented a certificate that does not match its
+// fingerprint or the certificate pinned for a favorite, e.g. a device impersonating a favorite.
+func SendPeerCertificateNotification(certErr *tool.PeerCertificateError) error {
+	notification := &types.Notification{
+		Type:    types.NotifyTypePeerCertificate,
+		Title:   "Peer Certificate Mismatch",
+		Message: certErr.Error(),
+		Data: map[string]any{
+			"fingerprint": certErr.Fingerprint,
+			"address":     certErr.Address,
+			"certSha256":  certErr.CertSHA256,
+			"pinned":      certErr.Pinned,
+		},
+	}
+	return SendNotification(notification, DefaultUnixSocketPath)
+}
+
 // isPlainTextType checks if the given file type is a plain text type
 func isPlainTextType(fileType string) bool {
 	if fileType == "" {
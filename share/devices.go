@@ -3,6 +3,7 @@ package share
 import (
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	ttlworker "github.com/FloatTech/ttl"
@@ -17,9 +18,46 @@ const (
 )
 
 var (
-	UserScanCurrent = ttlworker.NewCache[string, types.UserScanCurrentItem](DefaultTTL)
+	// UserScanCurrent holds the discovered devices by fingerprint. Every read of the cache resets the TTL of the
+	// entries read, so reads go through GetUserScanCurrent and ListUserScanCurrent, which drop entries not seen
+	// for DefaultTTL; the cache TTL only expires entries nobody reads.
+	UserScanCurrent = ttlworker.NewCacheOn(DefaultTTL, [4]func(string, types.UserScanCurrentItem){
+		nil, nil, onUserScanCurrentDelete, nil,
+	})
+
+	// quietDeletes holds keys whose next removal must not emit device_lost (e.g. scan-now clearing the list).
+	quietDeletes sync.Map
 )
 
+// onUserScanCurrentDelete marks the known device offline and emits device_lost when an entry expires or is removed as lost.
+// It runs under the cache lock (TTL gc), so the notification is sent asynchronously.
+func onUserScanCurrentDelete(key string, item types.UserScanCurrentItem) {
+	markKnownDeviceOffline(key)
+	if _, quiet := quietDeletes.LoadAndDelete(key); quiet || item.Ipaddress == "" {
+		return
+	}
+	go sendDeviceLostNotification(item)
+}
+
+func sendDeviceLostNotification(item types.UserScanCurrentItem) {
+	tool.DefaultLogger.Infof("Device lost: %s (%s) at %s", item.Alias, item.Fingerprint, item.Ipaddress)
+	notification := &types.Notification{
+		Type:    types.NotifyTypeDeviceLost,
+		Title:   "Device Lost",
+		Message: fmt.Sprintf("%s at %s", item.Alias, item.Ipaddress),
+		Data: map[string]any{
+			"fingerprint": item.Fingerprint,
+			"alias":       item.Alias,
+			"ip_address":  item.Ipaddress,
+			"lastSeen":    item.LastSeen,
+			"source":      item.Source,
+		},
+	}
+	if err := notify.SendNotification(notification, ""); err != nil {
+		tool.DefaultLogger.Debugf("Failed to send device notification: %v", err)
+	}
+}
+
 func SetUserScanCurrent(sessionId string, data types.UserScanCurrentItem) {
 	// Check if device exists and if info has changed
 	existing, exists := GetUserScanCurrent(sessionId)
@@ -27,8 +65,13 @@ func SetUserScanCurrent(sessionId string, data types.UserScanCurrentItem) {
 	isNew := !exists
 	isChanged := exists && hasDeviceInfoChanged(existing, data)
 
+	if data.LastSeen == 0 {
+		data.LastSeen = time.Now().UnixMilli()
+	}
+
 	// Set the new data
 	UserScanCurrent.Set(sessionId, data)
+	recordKnownDevice(data)
 	tool.DefaultLogger.Debugf("Set user scan current: %s", sessionId)
 
 	// Send notification if new device or info changed
@@ -56,6 +99,8 @@ func SetUserScanCurrent(sessionId string, data types.UserScanCurrentItem) {
 				"deviceType":  data.DeviceType,
 				"deviceModel": data.DeviceModel,
 				"version":     data.Version,
+				"source":      data.Source,
+				"lastSeen":    data.LastSeen,
 				"isNew":       isNew,
 			},
 		}
@@ -77,30 +122,75 @@ func hasDeviceInfoChanged(a, b types.UserScanCurrentItem) bool {
 		a.Version != b.Version
 }
 
+// GetUserScanCurrent returns a discovered device. A device not seen for DefaultTTL is removed as lost instead.
 func GetUserScanCurrent(sessionId string) (types.UserScanCurrentItem, bool) {
 	data := UserScanCurrent.Get(sessionId)
-	return data, data.Ipaddress != ""
+	if data.Ipaddress == "" {
+		return data, false
+	}
+	if isUserScanCurrentStale(data, time.Now()) {
+		UserScanCurrent.Delete(sessionId)
+		return types.UserScanCurrentItem{}, false
+	}
+	return data, true
 }
 
+// ListUserScanCurrent returns the keys of the discovered devices. Devices not seen for DefaultTTL are removed
+// as lost instead.
 func ListUserScanCurrent() []string {
+	now := time.Now()
 	keys := make([]string, 0)
+	var stale []string
 	err := UserScanCurrent.Range(func(k string, v types.UserScanCurrentItem) error {
-		keys = append(keys, k)
+		if isUserScanCurrentStale(v, now) {
+			stale = append(stale, k)
+		} else {
+			keys = append(keys, k)
+		}
 		return nil
 	})
+	// Deleted after Range, which holds the cache lock.
+	for _, k := range stale {
+		UserScanCurrent.Delete(k)
+	}
 	if err != nil {
 		return nil
 	}
 	return keys
 }
 
+// isUserScanCurrentStale reports whether a device was last seen DefaultTTL or longer ago.
+func isUserScanCurrentStale(item types.UserScanCurrentItem, now time.Time) bool {
+	return item.LastSeen > 0 && now.Sub(time.UnixMilli(item.LastSeen)) >= DefaultTTL
+}
+
 // ClearUserScanCurrent removes all entries from the scan result cache.
-// Used by scan-now to clear the list before performing a fresh scan.
+// Used by scan-now to clear the list before performing a fresh scan; no device_lost is emitted.
 func ClearUserScanCurrent() {
 	keys := ListUserScanCurrent()
 	for _, k := range keys {
+		quietDeletes.Store(k, struct{}{})
 		UserScanCurrent.Delete(k)
+		quietDeletes.Delete(k)
+	}
+}
+
+// MarkUserScanCurrentSeen refreshes LastSeen of a known device (e.g. after a successful heartbeat)
+// without emitting device_updated. Returns false if the device is not known.
+func MarkUserScanCurrentSeen(fingerprint string) bool {
+	item, ok := GetUserScanCurrent(fingerprint)
+	if !ok {
+		return false
 	}
+	item.LastSeen = time.Now().UnixMilli()
+	UserScanCurrent.Set(fingerprint, item)
+	recordKnownDevice(item)
+	return true
+}
+
+// RemoveLostUserScanCurrent removes a device that stopped responding and emits device_lost.
+func RemoveLostUserScanCurrent(fingerprint string) {
+	UserScanCurrent.Delete(fingerprint)
 }
 
 // GetSelfNetworkInfos returns all valid local network interfaces with their IP and segment number.
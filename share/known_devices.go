@@ -0,0 +1,260 @@
+package share
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	// KnownDevicesFileName is the registry file, stored next to the config file
+	KnownDevicesFileName = "known_devices.yaml"
+	// knownDevicesSaveDelay batches the registry writes caused by frequent announcements
+	knownDevicesSaveDelay = 10 * time.Second
+	// knownDeviceAliasHistoryMax bounds the previous aliases kept per device
+	knownDeviceAliasHistoryMax = 10
+)
+
+var (
+	// knownDevices is the persisted registry of every device seen, keyed by fingerprint.
+	knownDevicesMu        sync.Mutex
+	knownDevices          = make(map[string]*types.KnownDevice)
+	knownDevicesPath      string // set by LoadKnownDevices; empty keeps the registry in memory only
+	knownDevicesSaveTimer *time.Timer
+)
+
+// LoadKnownDevices loads the known-devices registry from path ("" = known_devices.yaml next to the config file)
+// and persists later changes there. A missing file is not an error. Loaded devices are offline until rediscovered.
+func LoadKnownDevices(path string) error {
+	if path == "" {
+		path = filepath.Join(filepath.Dir(tool.ConfigPath), KnownDevicesFileName)
+	}
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	knownDevicesPath = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read known devices: %v", err)
+	}
+	var file types.KnownDevicesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse known devices %s: %v", path, err)
+	}
+	for i := range file.Devices {
+		device := file.Devices[i]
+		if device.Fingerprint == "" {
+			continue
+		}
+		// Keep devices discovered before the registry was loaded.
+		if _, ok := knownDevices[device.Fingerprint]; ok {
+			continue
+		}
+		device.Online = false
+		knownDevices[device.Fingerprint] = &device
+	}
+	tool.DefaultLogger.Infof("Loaded %d known devices from %s", len(file.Devices), path)
+	return nil
+}
+
+// ListKnownDevices returns a copy of every known device, most recently seen first.
+func ListKnownDevices() []types.KnownDevice {
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	result := make([]types.KnownDevice, 0, len(knownDevices))
+	for _, device := range knownDevices {
+		copied := *device
+		copied.AliasHistory = append([]string(nil), device.AliasHistory...)
+		result = append(result, copied)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LastSeen != result[j].LastSeen {
+			return result[i].LastSeen > result[j].LastSeen
+		}
+		return result[i].Fingerprint < result[j].Fingerprint
+	})
+	return result
+}
+
+// KnownDeviceIP returns the last known IP address of a device, or "" when it is unknown or was never discovered.
+func KnownDeviceIP(fingerprint string) string {
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	if device, ok := knownDevices[fingerprint]; ok {
+		return device.IPAddress
+	}
+	return ""
+}
+
+// ForgetKnownDevice removes a device from the registry and saves it. Returns false if the device is not known.
+// A device that is still online is added again on its next announcement.
+func ForgetKnownDevice(fingerprint string) (bool, error) {
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	if _, ok := knownDevices[fingerprint]; !ok {
+		return false, nil
+	}
+	delete(knownDevices, fingerprint)
+	return true, saveKnownDevicesLocked()
+}
+
+// RecordKnownDeviceSent counts an upload session accepted by target with files accepted files.
+func RecordKnownDeviceSent(target types.UserScanCurrentItem, files int) {
+	if target.Fingerprint == "" {
+		return
+	}
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	device := knownDeviceLocked(target.Fingerprint, time.Now().UnixMilli())
+	if device.IPAddress == "" {
+		applyScanItemLocked(device, target)
+	}
+	device.SentSessions++
+	device.SentFiles += files
+	scheduleKnownDevicesSaveLocked()
+}
+
+// RecordKnownDeviceReceived counts an upload session accepted from sender with files files.
+// The sender's address is not known here, so a device first seen this way has no IP until discovered.
+func RecordKnownDeviceReceived(sender types.DeviceInfo, files int) {
+	if sender.Fingerprint == "" {
+		return
+	}
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	now := time.Now().UnixMilli()
+	device := knownDeviceLocked(sender.Fingerprint, now)
+	applyAliasLocked(device, sender.Alias)
+	if device.IPAddress == "" {
+		device.Port = sender.Port
+		device.Protocol = sender.Protocol
+		device.DeviceModel = sender.DeviceModel
+		device.DeviceType = sender.DeviceType
+		device.Version = sender.Version
+	}
+	device.LastSeen = now
+	device.ReceivedSessions++
+	device.ReceivedFiles += files
+	scheduleKnownDevicesSaveLocked()
+}
+
+// recordKnownDevice updates the registry from a scan-current entry and marks the device online.
+func recordKnownDevice(item types.UserScanCurrentItem) {
+	if item.Fingerprint == "" {
+		return
+	}
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	device := knownDeviceLocked(item.Fingerprint, item.LastSeen)
+	applyScanItemLocked(device, item)
+	device.Online = true
+	scheduleKnownDevicesSaveLocked()
+}
+
+// markKnownDeviceOffline flags a device removed from scan-current as offline; the flag is not persisted.
+func markKnownDeviceOffline(fingerprint string) {
+	knownDevicesMu.Lock()
+	defer knownDevicesMu.Unlock()
+	if device, ok := knownDevices[fingerprint]; ok {
+		device.Online = false
+	}
+}
+
+// knownDeviceLocked returns the entry of fingerprint, creating it first seen at now. knownDevicesMu must be held.
+func knownDeviceLocked(fingerprint string, now int64) *types.KnownDevice {
+	device, ok := knownDevices[fingerprint]
+	if !ok {
+		device = &types.KnownDevice{Fingerprint: fingerprint, FirstSeen: now, LastSeen: now}
+		knownDevices[fingerprint] = device
+	}
+	return device
+}
+
+// applyScanItemLocked copies the address and device info of a scan-current entry.
+func applyScanItemLocked(device *types.KnownDevice, item types.UserScanCurrentItem) {
+	applyAliasLocked(device, item.Alias)
+	device.IPAddress = item.Ipaddress
+	device.Port = item.Port
+	device.Protocol = item.Protocol
+	device.DeviceModel = item.DeviceModel
+	device.DeviceType = item.DeviceType
+	device.Version = item.Version
+	device.Source = item.Source
+	if item.LastSeen > device.LastSeen {
+		device.LastSeen = item.LastSeen
+	}
+}
+
+// applyAliasLocked sets the alias and moves the previous one to the alias history.
+func applyAliasLocked(device *types.KnownDevice, alias string) {
+	if alias == "" || alias == device.Alias {
+		return
+	}
+	if previous := device.Alias; previous != "" {
+		history := make([]string, 0, len(device.AliasHistory)+1)
+		for _, a := range device.AliasHistory {
+			if a != previous && a != alias {
+				history = append(history, a)
+			}
+		}
+		history = append(history, previous)
+		if len(history) > knownDeviceAliasHistoryMax {
+			history = history[len(history)-knownDeviceAliasHistoryMax:]
+		}
+		device.AliasHistory = history
+	}
+	device.Alias = alias
+}
+
+// scheduleKnownDevicesSaveLocked saves the registry after knownDevicesSaveDelay unless a save is already pending.
+func scheduleKnownDevicesSaveLocked() {
+	if knownDevicesPath == "" || knownDevicesSaveTimer != nil {
+		return
+	}
+	knownDevicesSaveTimer = time.AfterFunc(knownDevicesSaveDelay, func() {
+		knownDevicesMu.Lock()
+		defer knownDevicesMu.Unlock()
+		knownDevicesSaveTimer = nil
+		if err := saveKnownDevicesLocked(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to save known devices: %v", err)
+		}
+	})
+}
+
+// saveKnownDevicesLocked writes the registry through a temporary file so a crash never leaves it truncated.
+func saveKnownDevicesLocked() error {
+	if knownDevicesPath == "" {
+		return nil
+	}
+	file := types.KnownDevicesFile{Devices: make([]types.KnownDevice, 0, len(knownDevices))}
+	for _, device := range knownDevices {
+		file.Devices = append(file.Devices, *device)
+	}
+	sort.Slice(file.Devices, func(i, j int) bool {
+		if file.Devices[i].FirstSeen != file.Devices[j].FirstSeen {
+			return file.Devices[i].FirstSeen < file.Devices[j].FirstSeen
+		}
+		return file.Devices[i].Fingerprint < file.Devices[j].Fingerprint
+	})
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	tmp := knownDevicesPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, knownDevicesPath)
+}
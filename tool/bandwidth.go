@@ -0,0 +1,217 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/moyoez/localsend-go/types"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// minBandwidthBurst and maxBandwidthBurst bound the token bucket size (a tenth of a second of traffic).
+	minBandwidthBurst = 4 << 10
+	maxBandwidthBurst = 1 << 20
+)
+
+// peerLimiters holds the send and receive limiter of one peer.
+type peerLimiters struct {
+	send    *rate.Limiter
+	receive *rate.Limiter
+}
+
+var (
+	bandwidthMu     sync.RWMutex
+	bandwidthConfig types.BandwidthConfig
+	// The limiters live as long as the process, so limit changes reach readers that are waiting on them.
+	totalLimiter   = rate.NewLimiter(rate.Inf, 0)
+	sendLimiter    = rate.NewLimiter(rate.Inf, 0)
+	receiveLimiter = rate.NewLimiter(rate.Inf, 0)
+	peerBandwidth  = make(map[string]*peerLimiters) // by fingerprint or normalized IP address
+)
+
+// SetBandwidthLimits validates cfg and applies it to new and running transfers.
+func SetBandwidthLimits(cfg types.BandwidthConfig) error {
+	if cfg.TotalBytesPerSecond < 0 || cfg.SendBytesPerSecond < 0 || cfg.ReceiveBytesPerSecond < 0 {
+		return fmt.Errorf("bandwidth: rates must not be negative")
+	}
+	cfg.Peers = append([]types.PeerBandwidthLimit(nil), cfg.Peers...)
+	peers := make(map[string]types.PeerBandwidthLimit, len(cfg.Peers))
+	for i, limit := range cfg.Peers {
+		limit.Peer = normalizeBandwidthPeer(limit.Peer)
+		if limit.Peer == "" {
+			return fmt.Errorf("bandwidth: peer #%d has no fingerprint or IP address", i+1)
+		}
+		if limit.SendBytesPerSecond < 0 || limit.ReceiveBytesPerSecond < 0 {
+			return fmt.Errorf("bandwidth: rates of peer %s must not be negative", limit.Peer)
+		}
+		if _, ok := peers[limit.Peer]; ok {
+			return fmt.Errorf("bandwidth: peer %s listed twice", limit.Peer)
+		}
+		peers[limit.Peer] = limit
+		cfg.Peers[i] = limit
+	}
+
+	bandwidthMu.Lock()
+	defer bandwidthMu.Unlock()
+	setLimiterRate(totalLimiter, cfg.TotalBytesPerSecond)
+	setLimiterRate(sendLimiter, cfg.SendBytesPerSecond)
+	setLimiterRate(receiveLimiter, cfg.ReceiveBytesPerSecond)
+	for peer, limiters := range peerBandwidth {
+		if _, ok := peers[peer]; !ok {
+			// Release readers still waiting on a removed peer.
+			setLimiterRate(limiters.send, 0)
+			setLimiterRate(limiters.receive, 0)
+			delete(peerBandwidth, peer)
+		}
+	}
+	for peer, limit := range peers {
+		limiters := peerBandwidth[peer]
+		if limiters == nil {
+			limiters = &peerLimiters{send: rate.NewLimiter(rate.Inf, 0), receive: rate.NewLimiter(rate.Inf, 0)}
+			peerBandwidth[peer] = limiters
+		}
+		setLimiterRate(limiters.send, limit.SendBytesPerSecond)
+		setLimiterRate(limiters.receive, limit.ReceiveBytesPerSecond)
+	}
+	bandwidthConfig = cfg
+	if cfg.TotalBytesPerSecond > 0 || cfg.SendBytesPerSecond > 0 || cfg.ReceiveBytesPerSecond > 0 || len(peers) > 0 {
+		DefaultLogger.Infof("Bandwidth limits: total %d B/s, send %d B/s, receive %d B/s, %d peer limits (0 = unlimited)",
+			cfg.TotalBytesPerSecond, cfg.SendBytesPerSecond, cfg.ReceiveBytesPerSecond, len(peers))
+	}
+	return nil
+}
+
+// UpdateBandwidthLimits applies cfg like SetBandwidthLimits and writes it to the config file. When the file cannot
+// be written, the previous limits are restored.
+func UpdateBandwidthLimits(cfg types.BandwidthConfig) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	previous := GetBandwidthLimits()
+	if err := SetBandwidthLimits(cfg); err != nil {
+		return err
+	}
+	updated := CurrentConfig
+	updated.Bandwidth = GetBandwidthLimits()
+	if err := writeDefaultConfig(ConfigPath, updated); err != nil {
+		if rollbackErr := SetBandwidthLimits(previous); rollbackErr != nil {
+			DefaultLogger.Errorf("Failed to restore bandwidth limits: %v", rollbackErr)
+		}
+		return err
+	}
+	CurrentConfig.Bandwidth = updated.Bandwidth
+	return nil
+}
+
+// GetBandwidthLimits returns a copy of the active bandwidth limits.
+func GetBandwidthLimits() types.BandwidthConfig {
+	bandwidthMu.RLock()
+	defer bandwidthMu.RUnlock()
+	cfg := bandwidthConfig
+	cfg.Peers = append([]types.PeerBandwidthLimit{}, bandwidthConfig.Peers...)
+	return cfg
+}
+
+// setLimiterRate sets l to bytesPerSecond, or unlimited for 0.
+func setLimiterRate(l *rate.Limiter, bytesPerSecond int64) {
+	if bytesPerSecond <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	l.SetBurst(int(min(max(bytesPerSecond/10, minBandwidthBurst), maxBandwidthBurst)))
+	l.SetLimit(rate.Limit(bytesPerSecond))
+}
+
+// normalizeBandwidthPeer returns IP addresses in canonical form and fingerprints as they are.
+func normalizeBandwidthPeer(peer string) string {
+	peer = strings.TrimSpace(peer)
+	if addr, err := netip.ParseAddr(peer); err == nil {
+		return addr.WithZone("").Unmap().String()
+	}
+	return peer
+}
+
+// bandwidthLimiters returns the limited limiters that apply to a transfer with the peer in direction
+// (types.TransferInbound or types.TransferOutbound) and the smallest of their bursts.
+func bandwidthLimiters(direction, fingerprint, ip string) ([]*rate.Limiter, int) {
+	bandwidthMu.RLock()
+	defer bandwidthMu.RUnlock()
+	candidates := []*rate.Limiter{totalLimiter, sendLimiter}
+	if direction == types.TransferInbound {
+		candidates[1] = receiveLimiter
+	}
+	for _, peer := range []string{fingerprint, normalizeBandwidthPeer(ip)} {
+		if limiters := peerBandwidth[peer]; peer != "" && limiters != nil {
+			if direction == types.TransferInbound {
+				candidates = append(candidates, limiters.receive)
+			} else {
+				candidates = append(candidates, limiters.send)
+			}
+		}
+	}
+	var limited []*rate.Limiter
+	burst := 0
+	for _, l := range candidates {
+		if l.Limit() == rate.Inf {
+			continue
+		}
+		limited = append(limited, l)
+		if burst == 0 || l.Burst() < burst {
+			burst = l.Burst()
+		}
+	}
+	return limited, burst
+}
+
+// LimitReader returns r throttled by the bandwidth limits for a transfer with the peer (fingerprint and/or IP
+// address) in direction. Limits are looked up on every read, so runtime changes apply immediately.
+func LimitReader(ctx context.Context, r io.Reader, direction, fingerprint, ip string) io.Reader {
+	return &limitedReader{ctx: ctx, r: r, direction: direction, fingerprint: fingerprint, ip: ip}
+}
+
+type limitedReader struct {
+	ctx         context.Context
+	r           io.Reader
+	direction   string
+	fingerprint string
+	ip          string
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	limiters, burst := bandwidthLimiters(lr.direction, lr.fingerprint, lr.ip)
+	if len(limiters) == 0 {
+		return lr.r.Read(p)
+	}
+	if len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := lr.r.Read(p)
+	for _, l := range limiters {
+		if waitErr := waitBandwidth(lr.ctx, l, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// waitBandwidth takes n tokens from l in pieces no larger than its burst, which may change while waiting.
+func waitBandwidth(ctx context.Context, l *rate.Limiter, n int) error {
+	for n > 0 {
+		if l.Limit() == rate.Inf {
+			return nil
+		}
+		take := min(n, max(l.Burst(), 1))
+		if err := l.WaitN(ctx, take); err != nil {
+			if ctx.Err() != nil || take <= l.Burst() {
+				return err
+			}
+			continue // the burst shrank meanwhile
+		}
+		n -= take
+	}
+	return nil
+}
@@ -3,7 +3,9 @@ package tool
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 
@@ -14,6 +16,8 @@ var (
 	ConfigPath           = "config.yaml" // be aware that it can be changed, default to ./config.yaml
 	CurrentConfig        types.AppConfig
 	ProgramCurrentConfig types.ProgramConfig
+	// configMu guards runtime changes of CurrentConfig and the config file writes that follow them.
+	configMu sync.RWMutex
 )
 
 func init() {
@@ -129,12 +133,39 @@ func LoadConfig(path string) (types.AppConfig, error) {
 	return cfg, nil
 }
 
+// writeDefaultConfig writes cfg to path through a temporary file in the same directory, so a crash or a
+// concurrent reader never sees a half-written config. Runtime writers must hold configMu.
 func writeDefaultConfig(path string, cfg types.AppConfig) error {
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, 0o644); err != nil {
+		return err
+	}
+	err = os.Rename(tmpPath, path)
+	return err
 }
 
 func GetCurrentConfig() *types.AppConfig {
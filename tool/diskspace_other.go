@@ -0,0 +1,15 @@
+//go:build !linux && !windows && !darwin && !freebsd && !dragonfly
+
+package tool
+
+import "errors"
+
+// diskFreeBytes is not implemented on this platform; free-space checks are skipped.
+func diskFreeBytes(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// fileSystemID is not implemented on this platform; every path counts as its own file system.
+func fileSystemID(path string) (string, error) {
+	return path, nil
+}
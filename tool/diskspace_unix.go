@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package tool
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskFreeBytes returns the bytes available to unprivileged users on the file system holding path.
+func diskFreeBytes(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+// fileSystemID identifies the file system holding path, so roots on the same one share its free space.
+func fileSystemID(path string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(st.Dev), nil
+}
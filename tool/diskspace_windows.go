@@ -0,0 +1,36 @@
+//go:build windows
+
+package tool
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// diskFreeBytes returns the bytes available to the current user on the volume holding path.
+func diskFreeBytes(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
+
+// fileSystemID identifies the volume holding path by its mount point, so roots on the same volume share its
+// free space.
+func fileSystemID(path string) (string, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &buf[0], uint32(len(buf))); err != nil {
+		return "", err
+	}
+	return strings.ToLower(windows.UTF16ToString(buf)), nil
+}
@@ -1,6 +1,7 @@
 package tool
 
 import (
+	"fmt"
 	"os"
 	"sync"
 
@@ -9,13 +10,11 @@ import (
 	"github.com/moyoez/localsend-go/types"
 )
 
-var favoritesMu sync.RWMutex
-
 // AddFavorite adds a device to favorites by fingerprint and alias.
 // If the fingerprint already exists, the alias will be updated.
 func AddFavorite(fingerprint, alias string) error {
-	favoritesMu.Lock()
-	defer favoritesMu.Unlock()
+	configMu.Lock()
+	defer configMu.Unlock()
 
 	// Check if already exists, update alias if so
 	found := false
@@ -41,8 +40,8 @@ func AddFavorite(fingerprint, alias string) error {
 
 // ListFavorites returns a copy of the current favorite devices list.
 func ListFavorites() []types.FavoriteDeviceEntry {
-	favoritesMu.RLock()
-	defer favoritesMu.RUnlock()
+	configMu.RLock()
+	defer configMu.RUnlock()
 
 	// Return a copy to avoid race conditions
 	result := make([]types.FavoriteDeviceEntry, len(CurrentConfig.FavoriteDevices))
@@ -52,8 +51,8 @@ func ListFavorites() []types.FavoriteDeviceEntry {
 
 // RemoveFavorite removes a device from favorites by fingerprint.
 func RemoveFavorite(fingerprint string) error {
-	favoritesMu.Lock()
-	defer favoritesMu.Unlock()
+	configMu.Lock()
+	defer configMu.Unlock()
 
 	// Find and remove the entry
 	newList := make([]types.FavoriteDeviceEntry, 0, len(CurrentConfig.FavoriteDevices))
@@ -71,8 +70,8 @@ func RemoveFavorite(fingerprint string) error {
 // IsFavorite checks if a device with the given fingerprint is in favorites.
 // This function reads the config file in real-time to ensure up-to-date state.
 func IsFavorite(fingerprint string) bool {
-	favoritesMu.RLock()
-	defer favoritesMu.RUnlock()
+	configMu.RLock()
+	defer configMu.RUnlock()
 
 	// Read config file in real-time
 	data, err := os.ReadFile(ConfigPath)
@@ -107,3 +106,80 @@ func IsFavorite(fingerprint string) bool {
 	}
 	return false
 }
+
+var (
+	pendingPinsMu sync.Mutex
+	// pendingPins holds the certificate pins queued by QueueFavoriteCertificatePin, by favorite fingerprint
+	pendingPins      = make(map[string]string)
+	pendingPinsReady = make(chan struct{}, 1)
+	pinWriterOnce    sync.Once
+)
+
+// FavoriteCertificatePin returns the certificate SHA-256 pinned (or queued to be pinned) for a favorite,
+// or "" if it is not pinned.
+func FavoriteCertificatePin(fingerprint string) string {
+	pendingPinsMu.Lock()
+	pending := pendingPins[fingerprint]
+	pendingPinsMu.Unlock()
+	if pending != "" {
+		return pending
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	for _, fav := range CurrentConfig.FavoriteDevices {
+		if fav.Fingerprint == fingerprint {
+			return fav.CertSHA256
+		}
+	}
+	return ""
+}
+
+// QueueFavoriteCertificatePin pins the certificate SHA-256 of a favorite without waiting for the config file:
+// the pin applies at once and is written in the background. Used during TLS handshakes.
+func QueueFavoriteCertificatePin(fingerprint, certSHA256 string) {
+	pendingPinsMu.Lock()
+	if _, ok := pendingPins[fingerprint]; ok {
+		pendingPinsMu.Unlock()
+		return
+	}
+	pendingPins[fingerprint] = certSHA256
+	pendingPinsMu.Unlock()
+	pinWriterOnce.Do(func() { go writeQueuedPins() })
+	select {
+	case pendingPinsReady <- struct{}{}:
+	default: // the writer is already due to run
+	}
+}
+
+// writeQueuedPins persists the queued pins, keeping each one pending until it is written.
+func writeQueuedPins() {
+	for range pendingPinsReady {
+		pendingPinsMu.Lock()
+		pins := make(map[string]string, len(pendingPins))
+		for fingerprint, sum := range pendingPins {
+			pins[fingerprint] = sum
+		}
+		pendingPinsMu.Unlock()
+		for fingerprint, sum := range pins {
+			if err := PinFavoriteCertificate(fingerprint, sum); err != nil {
+				DefaultLogger.Errorf("Failed to pin certificate of favorite %s: %v", fingerprint, err)
+			}
+			pendingPinsMu.Lock()
+			delete(pendingPins, fingerprint)
+			pendingPinsMu.Unlock()
+		}
+	}
+}
+
+// PinFavoriteCertificate pins the certificate SHA-256 of a favorite and writes the config file.
+func PinFavoriteCertificate(fingerprint, certSHA256 string) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	for i, fav := range CurrentConfig.FavoriteDevices {
+		if fav.Fingerprint == fingerprint {
+			CurrentConfig.FavoriteDevices[i].CertSHA256 = certSHA256
+			return writeDefaultConfig(ConfigPath, CurrentConfig)
+		}
+	}
+	return fmt.Errorf("favorite %s not found", fingerprint)
+}
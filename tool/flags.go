@@ -12,16 +12,25 @@ func SetFlags() types.Config {
 	flag.StringVar(&cfg.Log, "log", "prod", "log mode: dev|prod|none")
 	flag.StringVar(&cfg.UseMultcastAddress, "useMultcastAddress", "", "override multicast address")
 	flag.IntVar(&cfg.UseMultcastPort, "useMultcastPort", 0, "override multicast port")
+	flag.StringVar(&cfg.UseMultcastAddressV6, "useMultcastAddressV6", "", "override IPv6 multicast address (default ff02::167)")
+	flag.StringVar(&cfg.UseIPv6Multicast, "useIPv6Multicast", "", "enable IPv6 multicast discovery: '*' for all interfaces or comma-separated names (e.g., 'eth0,wlan0'); empty disables it")
+	flag.StringVar(&cfg.UseBroadcast, "useBroadcast", "", "enable broadcast discovery (255.255.255.255 and directed broadcast) for networks that drop multicast: '*' for all interfaces or comma-separated names; empty disables it")
 	flag.StringVar(&cfg.UseConfigPath, "useConfigPath", "config.yaml", "override config file path")
 	flag.StringVar(&cfg.UseDefaultUploadFolder, "useDefaultUploadFolder", "uploads", "override default upload folder")
 	flag.StringVar(&cfg.UseReferNetworkInterface, "useReferNetworkInterface", "*", "specify network interface (e.g., 'en0', 'eth0') or '*' for all interfaces")
 	flag.StringVar(&cfg.UsePin, "usePin", "", "specify pin for upload (only for FROM upload request)")
 	flag.BoolVar(&cfg.UseAutoSave, "useAutoSave", false, "if false, user require to confirm before recv (only for FROM upload request)")
 	flag.BoolVar(&cfg.UseAutoSaveFromFavorites, "useAutoSaveFromFavorites", false, "if true and useAutoSave is false, auto-accept from favorite devices only")
+	flag.BoolVar(&cfg.InsecureSkipPeerVerify, "insecureSkipPeerVerify", false, "if true, do not check that peer TLS certificates match their fingerprint and do not pin favorites (insecure)")
 	flag.StringVar(&cfg.UseAlias, "useAlias", "", "specify alias for the device")
 	flag.BoolVar(&cfg.SkipNotify, "skipNotify", false, "if true, skip notify mode.")
 	flag.BoolVar(&cfg.UseHttp, "useHttp", false, "if true, use http; if false, use https. Alias for protocol config.")
 	flag.IntVar(&cfg.ScanTimeout, "scanTimeout", 500, "scan timeout in seconds, default 500. After timeout, auto scan will stop. Set to 0 to disable timeout.")
+	flag.IntVar(&cfg.HeartbeatInterval, "heartbeatInterval", 0, "probe known devices via /info every N seconds; a device missing 3 heartbeats is reported as device_lost. 0 disables it.")
+	flag.StringVar(&cfg.ProbeStrategy, "probeStrategy", "auto", "host probe before HTTP scan register: auto|icmp|tcp|arp|none (auto uses icmp when permitted, otherwise tcp)")
+	flag.BoolVar(&cfg.UseMDNS, "useMDNS", false, "if true, advertise and browse _localsend._tcp via mDNS / DNS-SD instead of UDP multicast and HTTP scanning (for networks that filter the LocalSend multicast group). Alias for -scanMode mdns.")
+	flag.StringVar(&cfg.ScanMode, "scanMode", "", "discovery mode: udp|http|mixed|mdns|off (off: no scanning, only stay discoverable). Empty uses the scanMode config key, default mixed.")
+	flag.IntVar(&cfg.RegisterResponseWindow, "registerResponseWindow", 60, "seconds a peer answered with /register is not answered again after its announcements; 0 answers every announcement")
 	flag.BoolVar(&cfg.UseDownload, "useDownload", false, "if true, enable download API (prepare-download, download, download page)")
 	flag.StringVar(&cfg.UseWebOutPath, "useWebOutPath", "", "path to Next.js static export output for download page, maybe you dont need to change.")
 	flag.BoolVar(&cfg.DoNotMakeSessionFolder, "doNotMakeSessionFolder", false, "if true, do not create session subfolder; when file name exists, save as name-2.ext, name-3.ext, ...")
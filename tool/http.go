@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,25 +16,70 @@ var (
 	// so that non-responding IPs fail fast and scan-now returns in seconds instead of ~30s.
 	ScanTimeout       = 5 * time.Second
 	ScanDialTimeout   = 3 * time.Second // dial timeout for scan client
-	ConnectionHttpClient *http.Client
-	DetectHttpClient     *http.Client
-	ScanDetectHttpClient *http.Client
+	// The clients are replaced at runtime when the network changes (InitHTTPClients), hence atomic.
+	ConnectionHttpClient atomic.Pointer[http.Client]
+	DetectHttpClient     atomic.Pointer[http.Client]
+	ScanDetectHttpClient atomic.Pointer[http.Client]
 )
 
 func init() {
-	ConnectionHttpClient = NewHTTPClient()
-	DetectHttpClient = NewHTTPClient()
-	ScanDetectHttpClient = newHTTPClientForScan(nil)
+	ConnectionHttpClient.Store(NewHTTPClient())
+	DetectHttpClient.Store(NewHTTPClient())
+	ScanDetectHttpClient.Store(newHTTPClientForScan(nil))
 }
 
-// NewHTTPClient creates an HTTP client, skipping self-signed certificate verification in HTTPS mode.
+// NewHTTPClient creates an HTTP client. Self-signed certificates are accepted; requests made with
+// WithPeerFingerprint check the certificate against the peer fingerprint instead.
 func NewHTTPClient() *http.Client {
 	return newHTTPClientWithBindAddr(nil)
 }
 
-// newHTTPClientWithBindAddr creates an HTTP client. When bindAddr is non-nil, outgoing connections
-// are bound to that local address (e.g. to force use of a specific network interface).
-func newHTTPClientWithBindAddr(bindAddr *net.TCPAddr) *http.Client {
+// BindAddrs are the local addresses outgoing connections are bound to (e.g. to force use of a specific network
+// interface), one per address family since a dial needs a local address of the same family as the peer.
+// A nil address leaves dials of that kind unbound.
+type BindAddrs struct {
+	IPv4          *net.TCPAddr
+	IPv6          *net.TCPAddr // global or unique local address
+	IPv6LinkLocal *net.TCPAddr // with Zone set to the interface
+}
+
+// localAddrFor returns the bind address for a dial to addr (host:port), or nil to leave it unbound.
+func (b *BindAddrs) localAddrFor(addr string) *net.TCPAddr {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	host, _, _ = strings.Cut(host, "%")
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return nil
+	case ip.To4() != nil:
+		return b.IPv4
+	case ip.IsLinkLocalUnicast():
+		return b.IPv6LinkLocal
+	default:
+		return b.IPv6
+	}
+}
+
+// bindingDialContext returns a DialContext that binds each connection to the address of bindAddrs matching
+// the family of the peer.
+func bindingDialContext(dialer *net.Dialer, bindAddrs *BindAddrs) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		local := bindAddrs.localAddrFor(addr)
+		if local == nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		bound := *dialer
+		bound.LocalAddr = local
+		return bound.DialContext(ctx, network, addr)
+	}
+}
+
+// newHTTPClientWithBindAddr creates an HTTP client. When bindAddrs is non-nil, outgoing connections
+// are bound to its address for the peer's family.
+func newHTTPClientWithBindAddr(bindAddrs *BindAddrs) *http.Client {
 	transport := &http.Transport{
 		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
 		MaxIdleConns:        50,
@@ -40,25 +87,22 @@ func newHTTPClientWithBindAddr(bindAddr *net.TCPAddr) *http.Client {
 		IdleConnTimeout:     300 * time.Millisecond,
 		DisableKeepAlives:   false,
 	}
-	if bindAddr != nil {
+	if bindAddrs != nil {
 		dialer := &net.Dialer{
-			LocalAddr: bindAddr,
 			Timeout:   DefaultTimeout,
 			KeepAlive: 30 * time.Second,
 		}
-		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.DialContext(ctx, network, addr)
-		}
+		transport.DialContext = bindingDialContext(dialer, bindAddrs)
 	}
 	return &http.Client{
 		Timeout:   DefaultTimeout,
-		Transport: transport,
+		Transport: withPeerVerification(transport),
 	}
 }
 
 // newHTTPClientForScan creates an HTTP client for device scanning (scan-now) with short timeouts
 // so that non-responding IPs fail fast; overall timeout ScanTimeout (e.g. 5s), dial timeout ScanDialTimeout (e.g. 3s).
-func newHTTPClientForScan(bindAddr *net.TCPAddr) *http.Client {
+func newHTTPClientForScan(bindAddrs *BindAddrs) *http.Client {
 	transport := &http.Transport{
 		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
 		MaxIdleConns:        50,
@@ -67,39 +111,43 @@ func newHTTPClientForScan(bindAddr *net.TCPAddr) *http.Client {
 		DisableKeepAlives:   false,
 	}
 	dialTimeout := ScanDialTimeout
-	if bindAddr != nil {
+	if bindAddrs != nil {
 		dialer := &net.Dialer{
-			LocalAddr: bindAddr,
 			Timeout:   dialTimeout,
 			KeepAlive: 30 * time.Second,
 		}
-		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.DialContext(ctx, network, addr)
-		}
+		transport.DialContext = bindingDialContext(dialer, bindAddrs)
 	} else {
 		dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
 		transport.DialContext = dialer.DialContext
 	}
 	return &http.Client{
 		Timeout:   ScanTimeout,
-		Transport: transport,
+		Transport: withPeerVerification(transport),
 	}
 }
 
-// InitHTTPClients (re)initializes the HTTP clients with optional bind address.
-// Call this after boardcast.SetReferNetworkInterface. When bindAddr is nil (e.g. useReferNetworkInterface is "*"),
-// clients use the default transport without interface binding.
-func InitHTTPClients(bindAddr *net.TCPAddr) {
-	ConnectionHttpClient = newHTTPClientWithBindAddr(bindAddr)
-	DetectHttpClient = newHTTPClientWithBindAddr(bindAddr)
-	ScanDetectHttpClient = newHTTPClientForScan(bindAddr)
+// InitHTTPClients (re)initializes the HTTP clients with optional bind addresses.
+// Call this after boardcast.SetReferNetworkInterface. When bindAddrs is nil (e.g. useReferNetworkInterface is "*"),
+// clients use the default transport without interface binding. Requests running on the old clients finish
+// normally; their idle connections are closed.
+func InitHTTPClients(bindAddrs *BindAddrs) {
+	replaceHTTPClient(&ConnectionHttpClient, newHTTPClientWithBindAddr(bindAddrs))
+	replaceHTTPClient(&DetectHttpClient, newHTTPClientWithBindAddr(bindAddrs))
+	replaceHTTPClient(&ScanDetectHttpClient, newHTTPClientForScan(bindAddrs))
+}
+
+func replaceHTTPClient(current *atomic.Pointer[http.Client], client *http.Client) {
+	if old := current.Swap(client); old != nil {
+		old.CloseIdleConnections()
+	}
 }
 
 func GetHttpClient() *http.Client {
-	return ConnectionHttpClient
+	return ConnectionHttpClient.Load()
 }
 
 // GetScanHttpClient returns the HTTP client used for device scanning (scan-now), with short timeouts.
 func GetScanHttpClient() *http.Client {
-	return ScanDetectHttpClient
+	return ScanDetectHttpClient.Load()
 }
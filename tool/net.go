@@ -2,19 +2,22 @@ package tool
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/moyoez/localsend-go/types"
 	probing "github.com/prometheus-community/pro-bing"
 )
 
-// UDP4 unsupport multicast
-func RejectUnsupportNetworkInterface(iface *net.Interface) bool {
+// rejectInterfaceFlags rejects interfaces that are down, loopback, point-to-point or lack multicast.
+func rejectInterfaceFlags(iface *net.Interface) bool {
 	if iface.Flags&net.FlagUp == 0 {
 		return true
 	}
@@ -28,7 +31,12 @@ func RejectUnsupportNetworkInterface(iface *net.Interface) bool {
 		return true
 	}
 	// Ban "Meta" (Clash) router interfaces by ignoring interfaces with "Meta" in the name.
-	if strings.Contains(iface.Name, "Meta") {
+	return strings.Contains(iface.Name, "Meta")
+}
+
+// UDP4 unsupport multicast
+func RejectUnsupportNetworkInterface(iface *net.Interface) bool {
+	if rejectInterfaceFlags(iface) {
 		return true
 	}
 
@@ -45,6 +53,103 @@ func RejectUnsupportNetworkInterface(iface *net.Interface) bool {
 	return true
 }
 
+// RejectUnsupportNetworkInterfaceV6 is the IPv6 counterpart of RejectUnsupportNetworkInterface:
+// the interface must pass the same flag checks and carry at least one non-loopback IPv6 address.
+func RejectUnsupportNetworkInterfaceV6(iface *net.Interface) bool {
+	if rejectInterfaceFlags(iface) {
+		return true
+	}
+	ips, err := iface.Addrs()
+	if err != nil {
+		return true
+	}
+	for _, ip := range ips {
+		if ipnet, ok := ip.(*net.IPNet); ok && ipnet.IP.To4() == nil && ipnet.IP.To16() != nil && !ipnet.IP.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
+
+// IPStringWithZone returns the textual IP, appending "%zone" for IPv6 link-local addresses
+// so they stay routable (e.g. fe80::1%eth0).
+func IPStringWithZone(ip net.IP, zone string) string {
+	if zone != "" && ip.To4() == nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+		return ip.String() + "%" + zone
+	}
+	return ip.String()
+}
+
+// parseIPString parses an IPv4 or IPv6 literal, optionally bracketed and with a zone (fe80::1%eth0),
+// and unmaps IPv4-mapped IPv6 addresses (::ffff:192.168.1.2 -> 192.168.1.2).
+func parseIPString(s string) (netip.Addr, error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+	// URL form escapes the zone separator (fe80::1%25eth0).
+	s = strings.Replace(s, "%25", "%", 1)
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid IP address %q", s)
+	}
+	return addr.Unmap(), nil
+}
+
+// NormalizeIPString returns the canonical textual form of an IP literal, keeping IPv6 zones.
+func NormalizeIPString(s string) (string, error) {
+	addr, err := parseIPString(s)
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
+
+// SameIP reports whether two IP literals name the same address, ignoring IPv6 zones and IPv4 mapping.
+func SameIP(a, b string) bool {
+	addrA, err := parseIPString(a)
+	if err != nil {
+		return false
+	}
+	addrB, err := parseIPString(b)
+	if err != nil {
+		return false
+	}
+	return addrA.WithZone("") == addrB.WithZone("")
+}
+
+// ParseTargetUDPAddr builds the address of a peer from its stored IP string. IPv4 peers get a
+// 4-byte IP, IPv6 peers keep their zone so link-local addresses stay routable.
+func ParseTargetUDPAddr(ip string, port int) (*net.UDPAddr, error) {
+	addr, err := parseIPString(ip)
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: net.IP(addr.AsSlice()), Port: port, Zone: addr.Zone()}, nil
+}
+
+// PeerIP returns the socket peer address of the request, ignoring forwarding headers.
+func PeerIP(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	if normalized, err := NormalizeIPString(host); err == nil {
+		return normalized
+	}
+	return host
+}
+
+// ClientIP wraps gin's ClientIP so IPv6 peers match the addresses stored by discovery:
+// gin returns "" for zoned link-local peers (falls back to PeerIP) and keeps IPv4-mapped forms.
+func ClientIP(c *gin.Context) string {
+	ip := c.ClientIP()
+	if ip == "" {
+		return PeerIP(c)
+	}
+	if normalized, err := NormalizeIPString(ip); err == nil {
+		return normalized
+	}
+	return ip
+}
+
 func GetLocalIPv4Set() map[string]struct{} {
 	result := make(map[string]struct{})
 
@@ -0,0 +1,252 @@
+package tool
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Peers advertise the hash of their TLS certificate as fingerprint (see GetOrCreateFingerprintFromConfig).
+// Requests whose context carries the expected fingerprint (WithPeerFingerprint) fail when the peer presents
+// another certificate. Favorites are additionally pinned to the full certificate hash on first use.
+
+type peerFingerprintKey struct{}
+
+var (
+	// insecureSkipPeerVerify disables peer certificate checks and favorite pinning (-insecureSkipPeerVerify).
+	insecureSkipPeerVerify atomic.Bool
+
+	peerCertificateErrorHandlerMu sync.RWMutex
+	peerCertificateErrorHandler   func(*PeerCertificateError)
+)
+
+// PeerCertificateError reports a peer whose certificate does not match its fingerprint or the certificate
+// pinned for it, or a pinned favorite reached over plain HTTP.
+type PeerCertificateError struct {
+	Fingerprint string // expected (advertised) fingerprint
+	Address     string // host:port of the peer
+	CertSHA256  string // SHA-256 of the presented certificate, "" for plain HTTP
+	Pinned      string // certificate SHA-256 pinned for the favorite, "" if not pinned
+}
+
+func (e *PeerCertificateError) Error() string {
+	switch {
+	case e.CertSHA256 == "":
+		return fmt.Sprintf("peer %s (%s) is pinned to certificate %s but was reached without TLS", e.Address, e.Fingerprint, e.Pinned)
+	case e.Pinned != "":
+		return fmt.Sprintf("certificate of pinned peer %s (%s) changed: pinned %s, got %s", e.Address, e.Fingerprint, e.Pinned, e.CertSHA256)
+	default:
+		return fmt.Sprintf("certificate of peer %s (sha256 %s) does not match fingerprint %s", e.Address, e.CertSHA256, e.Fingerprint)
+	}
+}
+
+// SetInsecureSkipPeerVerify disables (true) or enables the peer certificate checks.
+func SetInsecureSkipPeerVerify(skip bool) {
+	insecureSkipPeerVerify.Store(skip)
+}
+
+// GetInsecureSkipPeerVerify reports whether the peer certificate checks are disabled.
+func GetInsecureSkipPeerVerify() bool {
+	return insecureSkipPeerVerify.Load()
+}
+
+// SetPeerCertificateErrorHandler sets the function called on every peer certificate mismatch (e.g. to notify the user).
+func SetPeerCertificateErrorHandler(handler func(*PeerCertificateError)) {
+	peerCertificateErrorHandlerMu.Lock()
+	defer peerCertificateErrorHandlerMu.Unlock()
+	peerCertificateErrorHandler = handler
+}
+
+// WithPeerFingerprint returns a context whose HTTPS requests only succeed if the peer's certificate matches fingerprint.
+func WithPeerFingerprint(ctx context.Context, fingerprint string) context.Context {
+	if fingerprint == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, peerFingerprintKey{}, fingerprint)
+}
+
+func peerFingerprintFromContext(ctx context.Context) string {
+	fingerprint, _ := ctx.Value(peerFingerprintKey{}).(string)
+	return fingerprint
+}
+
+// CertificateFingerprint returns the fingerprint of a DER certificate, computed like GetOrCreateFingerprintFromConfig.
+func CertificateFingerprint(der []byte) string {
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:16])
+}
+
+// certificateSHA256 returns the full SHA-256 of a DER certificate, the pinned value.
+func certificateSHA256(der []byte) string {
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:])
+}
+
+// certificateMatchesFingerprint accepts our truncated form and the full SHA-256 advertised by the official clients.
+func certificateMatchesFingerprint(der []byte, fingerprint string) bool {
+	fingerprint = strings.ToLower(fingerprint)
+	return fingerprint == CertificateFingerprint(der) || fingerprint == certificateSHA256(der)
+}
+
+// VerifyPeerCertificate checks that the certificate presented at address matches fingerprint and, for favorites,
+// the pinned certificate. An unpinned favorite is pinned to the certificate (trust on first use).
+func VerifyPeerCertificate(fingerprint, address string, der []byte) error {
+	if insecureSkipPeerVerify.Load() || fingerprint == "" {
+		return nil
+	}
+	sum := certificateSHA256(der)
+	if !certificateMatchesFingerprint(der, fingerprint) {
+		return reportPeerCertificateError(&PeerCertificateError{
+			Fingerprint: fingerprint,
+			Address:     address,
+			CertSHA256:  sum,
+			Pinned:      FavoriteCertificatePin(fingerprint),
+		})
+	}
+	if !IsFavorite(fingerprint) {
+		return nil
+	}
+	pinned := FavoriteCertificatePin(fingerprint)
+	if pinned == "" {
+		// Called during TLS handshakes: the config file is written in the background.
+		QueueFavoriteCertificatePin(fingerprint, sum)
+		DefaultLogger.Infof("Pinned certificate %s for favorite %s (%s)", sum, fingerprint, address)
+		return nil
+	}
+	if pinned != sum {
+		return reportPeerCertificateError(&PeerCertificateError{
+			Fingerprint: fingerprint,
+			Address:     address,
+			CertSHA256:  sum,
+			Pinned:      pinned,
+		})
+	}
+	return nil
+}
+
+// VerifyResponsePeerFingerprint checks the certificate of an HTTPS response against the fingerprint the peer
+// advertised in its body (e.g. /info or /register). Plain HTTP responses pass unless the favorite is pinned.
+func VerifyResponsePeerFingerprint(resp *http.Response, fingerprint string) error {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	return verifyResponseCertificate(resp, resp.Request.URL.Host, fingerprint)
+}
+
+func verifyResponseCertificate(resp *http.Response, address, fingerprint string) error {
+	if insecureSkipPeerVerify.Load() || fingerprint == "" {
+		return nil
+	}
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return verifyPlainPeer(fingerprint, address)
+	}
+	return VerifyPeerCertificate(fingerprint, address, resp.TLS.PeerCertificates[0].Raw)
+}
+
+// PeerResponseVerified reports whether resp came over TLS with a certificate matching fingerprint, i.e. whether
+// the peer proved it owns fingerprint. Plain HTTP responses prove nothing, even when they are not refused.
+func PeerResponseVerified(resp *http.Response, fingerprint string) bool {
+	if resp == nil || fingerprint == "" || resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	return certificateMatchesFingerprint(resp.TLS.PeerCertificates[0].Raw, fingerprint)
+}
+
+// verifyPlainPeer refuses plain HTTP to a favorite pinned to a certificate (downgrade).
+func verifyPlainPeer(fingerprint, address string) error {
+	if pinned := FavoriteCertificatePin(fingerprint); pinned != "" {
+		return reportPeerCertificateError(&PeerCertificateError{Fingerprint: fingerprint, Address: address, Pinned: pinned})
+	}
+	return nil
+}
+
+func reportPeerCertificateError(err *PeerCertificateError) error {
+	DefaultLogger.Errorf("[PeerVerify] %v", err)
+	peerCertificateErrorHandlerMu.RLock()
+	handler := peerCertificateErrorHandler
+	peerCertificateErrorHandlerMu.RUnlock()
+	if handler != nil {
+		handler(err)
+	}
+	return err
+}
+
+// peerTLSDialContext returns a DialTLSContext that verifies the peer certificate against the fingerprint
+// in the request context during the handshake, before anything is sent.
+func peerTLSDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error), config *tls.Config) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		rawConn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := config.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) == nil {
+			cfg.ServerName = host
+		}
+		if fingerprint := peerFingerprintFromContext(ctx); fingerprint != "" {
+			cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 {
+					return fmt.Errorf("peer %s presented no certificate", addr)
+				}
+				return VerifyPeerCertificate(fingerprint, addr, rawCerts[0])
+			}
+		}
+		conn := tls.Client(rawConn, cfg)
+		if err := conn.HandshakeContext(ctx); err != nil {
+			_ = rawConn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// peerVerifyingTransport checks responses of requests carrying a peer fingerprint: keep-alive connections
+// dialed for another fingerprint are re-checked and pinned favorites are refused over plain HTTP.
+type peerVerifyingTransport struct {
+	base *http.Transport
+}
+
+func (t *peerVerifyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fingerprint := peerFingerprintFromContext(req.Context())
+	if fingerprint == "" || insecureSkipPeerVerify.Load() {
+		return t.base.RoundTrip(req)
+	}
+	if req.URL.Scheme != "https" {
+		if err := verifyPlainPeer(fingerprint, req.URL.Host); err != nil {
+			return nil, err
+		}
+		return t.base.RoundTrip(req)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyResponseCertificate(resp, req.URL.Host, fingerprint); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections lets http.Client.CloseIdleConnections reach the wrapped transport.
+func (t *peerVerifyingTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// withPeerVerification makes transport check peer certificates against the fingerprint of the request context.
+func withPeerVerification(transport *http.Transport) http.RoundTripper {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialTLSContext = peerTLSDialContext(dial, transport.TLSClientConfig)
+	return &peerVerifyingTransport{base: transport}
+}
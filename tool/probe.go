@@ -0,0 +1,183 @@
+package tool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+// Probe strategies used to check host reachability before an HTTP register (legacy scanning).
+const (
+	ProbeStrategyAuto = "auto" // detect at startup: icmp if permitted, otherwise tcp
+	ProbeStrategyICMP = "icmp" // unprivileged ICMP echo
+	ProbeStrategyTCP  = "tcp"  // TCP connect to the LocalSend port
+	ProbeStrategyARP  = "arp"  // complete entry in the kernel ARP cache (/proc/net/arp, Linux only)
+	ProbeStrategyNone = "none" // no probe, register every address
+)
+
+// arpCachePath is the Linux ARP table.
+const arpCachePath = "/proc/net/arp"
+
+// arpCacheTTL is how long a parsed ARP table is reused between lookups.
+const arpCacheTTL = time.Second
+
+var (
+	probeStatusMu sync.RWMutex
+	probeStatus   = types.ProbeStatus{Requested: ProbeStrategyICMP, Strategy: ProbeStrategyICMP, TCP: true}
+
+	arpCacheMu      sync.Mutex
+	arpCacheEntries map[string]struct{}
+	arpCacheAt      time.Time
+)
+
+// InitProbeStrategy checks which probes work on this host and selects strategy.
+// "auto" (or "") picks icmp when unprivileged ICMP is permitted and tcp otherwise. The choice is logged.
+func InitProbeStrategy(strategy string) error {
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	if strategy == "" {
+		strategy = ProbeStrategyAuto
+	}
+	switch strategy {
+	case ProbeStrategyAuto, ProbeStrategyICMP, ProbeStrategyTCP, ProbeStrategyARP, ProbeStrategyNone:
+	default:
+		return fmt.Errorf("unknown probe strategy %q (want auto|icmp|tcp|arp|none)", strategy)
+	}
+
+	status := types.ProbeStatus{Requested: strategy, TCP: true}
+	icmpErr := checkICMPAvailable()
+	status.ICMP = icmpErr == nil
+	_, arpErr := readARPCache()
+	status.ARP = arpErr == nil
+
+	status.Strategy = strategy
+	if strategy == ProbeStrategyAuto {
+		status.Strategy = ProbeStrategyTCP
+		if status.ICMP {
+			status.Strategy = ProbeStrategyICMP
+		}
+	}
+	switch {
+	case status.Strategy == ProbeStrategyICMP && !status.ICMP:
+		DefaultLogger.Warnf("Probe strategy icmp requested but ICMP is unavailable (%v), every host will look unreachable", icmpErr)
+	case status.Strategy == ProbeStrategyARP && !status.ARP:
+		DefaultLogger.Warnf("Probe strategy arp requested but the ARP cache is unavailable (%v), every host will look unreachable", arpErr)
+	}
+	DefaultLogger.Infof("Host probe strategy: %s (requested: %s, icmp: %t, tcp: %t, arp: %t)",
+		status.Strategy, status.Requested, status.ICMP, status.TCP, status.ARP)
+
+	probeStatusMu.Lock()
+	probeStatus = status
+	probeStatusMu.Unlock()
+	return nil
+}
+
+// GetProbeStatus returns the selected probe strategy and the detected probe availability.
+func GetProbeStatus() types.ProbeStatus {
+	probeStatusMu.RLock()
+	defer probeStatusMu.RUnlock()
+	return probeStatus
+}
+
+// ProbeHost reports whether ip looks reachable using the selected probe strategy.
+// port is the LocalSend port, used by the tcp strategy.
+func ProbeHost(ip string, port int, timeout time.Duration) bool {
+	switch GetProbeStatus().Strategy {
+	case ProbeStrategyNone:
+		return true
+	case ProbeStrategyTCP:
+		return QuickTCPProbe(ip, port, timeout)
+	case ProbeStrategyARP:
+		return ARPCacheContains(ip)
+	default:
+		return QuickICMPProbe(ip, timeout)
+	}
+}
+
+// QuickTCPProbe checks if a host accepts a TCP connection on port within timeout.
+// A refused connection means the host is up but nothing listens there, so it counts as unreachable.
+func QuickTCPProbe(ip string, port int, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", FormatURLHost(ip, port), timeout)
+	if err != nil {
+		return false
+	}
+	if err := conn.Close(); err != nil {
+		DefaultLogger.Debugf("QuickTCPProbe: close %s: %v", ip, err)
+	}
+	DefaultLogger.Debugf("QuickTCPProbe: %s:%d accepted", ip, port)
+	return true
+}
+
+// ARPCacheContains reports whether ip has a complete entry in the kernel ARP cache.
+// Only hosts the kernel talked to recently are listed, so this finds fewer peers than the other probes.
+func ARPCacheContains(ip string) bool {
+	arpCacheMu.Lock()
+	defer arpCacheMu.Unlock()
+	if arpCacheEntries == nil || time.Since(arpCacheAt) > arpCacheTTL {
+		entries, err := readARPCache()
+		if err != nil {
+			DefaultLogger.Debugf("ARPCacheContains: %v", err)
+			return false
+		}
+		arpCacheEntries = entries
+		arpCacheAt = time.Now()
+	}
+	_, ok := arpCacheEntries[ip]
+	return ok
+}
+
+// readARPCache parses /proc/net/arp and returns the IPs with a complete (ATF_COM) entry.
+func readARPCache() (map[string]struct{}, error) {
+	f, err := os.Open(arpCachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			DefaultLogger.Debugf("readARPCache: close: %v", err)
+		}
+	}()
+	entries := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header: IP address, HW type, Flags, HW address, Mask, Device
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil || flags&0x2 == 0 {
+			continue
+		}
+		entries[fields[0]] = struct{}{}
+	}
+	return entries, scanner.Err()
+}
+
+// checkICMPAvailable pings the loopback address to see whether unprivileged ICMP is permitted
+// (net.ipv4.ping_group_range on Linux, usually denied in containers).
+func checkICMPAvailable() error {
+	pinger, err := probing.NewPinger("127.0.0.1")
+	if err != nil {
+		return err
+	}
+	pinger.SetPrivileged(false)
+	pinger.Count = 1
+	pinger.Timeout = 500 * time.Millisecond
+	pinger.SetNetwork("ip4")
+	if err := pinger.Run(); err != nil {
+		return fmt.Errorf("unprivileged ICMP not available: %w", err)
+	}
+	if pinger.Statistics().PacketsRecv == 0 {
+		return errors.New("no ICMP echo reply from loopback")
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package tool
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// receivePolicyRule is a validated ReceivePolicyRule with its conditions pre-parsed.
+type receivePolicyRule struct {
+	types.ReceivePolicyRule
+	name        string
+	senders     []netip.Prefix
+	windowStart int // minutes after midnight, -1 = any time
+	windowEnd   int
+}
+
+var (
+	receivePolicyMu sync.RWMutex
+	receivePolicy   []receivePolicyRule
+)
+
+// SetReceivePolicy validates rules and makes them the active receive policy. On error the policy is unchanged.
+func SetReceivePolicy(rules []types.ReceivePolicyRule) error {
+	compiled := make([]receivePolicyRule, 0, len(rules))
+	for i, rule := range rules {
+		c, err := compileReceivePolicyRule(i, rule)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+	receivePolicyMu.Lock()
+	receivePolicy = compiled
+	receivePolicyMu.Unlock()
+	if len(compiled) > 0 {
+		DefaultLogger.Infof("Receive policy: %d rules loaded", len(compiled))
+	}
+	return nil
+}
+
+func compileReceivePolicyRule(index int, rule types.ReceivePolicyRule) (receivePolicyRule, error) {
+	c := receivePolicyRule{ReceivePolicyRule: rule, name: rule.Name, windowStart: -1, windowEnd: -1}
+	if c.name == "" {
+		c.name = fmt.Sprintf("#%d", index+1)
+	}
+	c.Action = strings.ToLower(strings.TrimSpace(rule.Action))
+	switch c.Action {
+	case types.ReceiveActionAccept, types.ReceiveActionReject, types.ReceiveActionAsk:
+	case types.ReceiveActionAcceptSubset:
+		if len(rule.MimeTypes) == 0 && len(rule.Extensions) == 0 {
+			return c, fmt.Errorf("receive policy rule %s: accept-subset needs mimeTypes or extensions", c.name)
+		}
+	default:
+		return c, fmt.Errorf("receive policy rule %s: invalid action %q (use accept, reject, ask or accept-subset)", c.name, rule.Action)
+	}
+	for _, sender := range rule.Senders {
+		sender = strings.TrimSpace(sender)
+		if !strings.Contains(sender, "/") {
+			addr, err := netip.ParseAddr(sender)
+			if err != nil {
+				return c, fmt.Errorf("receive policy rule %s: invalid sender %q", c.name, sender)
+			}
+			c.senders = append(c.senders, netip.PrefixFrom(addr.WithZone("").Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(sender)
+		if err != nil {
+			return c, fmt.Errorf("receive policy rule %s: invalid sender CIDR %q", c.name, sender)
+		}
+		// Senders are matched unmapped, so ::ffff:10.0.0.0/104 must become 10.0.0.0/8.
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		c.senders = append(c.senders, prefix.Masked())
+	}
+	c.Aliases = lowerAll(rule.Aliases)
+	for _, pattern := range c.Aliases {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return c, fmt.Errorf("receive policy rule %s: invalid alias pattern %q", c.name, pattern)
+		}
+	}
+	c.MimeTypes = lowerAll(rule.MimeTypes)
+	for _, pattern := range c.MimeTypes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return c, fmt.Errorf("receive policy rule %s: invalid mime type pattern %q", c.name, pattern)
+		}
+	}
+	c.Extensions = make([]string, 0, len(rule.Extensions))
+	for _, ext := range lowerAll(rule.Extensions) {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		c.Extensions = append(c.Extensions, ext)
+	}
+	if rule.TimeOfDay != "" {
+		from, to, ok := strings.Cut(rule.TimeOfDay, "-")
+		start, err1 := parseClockMinutes(from)
+		end, err2 := parseClockMinutes(to)
+		if !ok || err1 != nil || err2 != nil {
+			return c, fmt.Errorf("receive policy rule %s: invalid timeOfDay %q (use HH:MM-HH:MM)", c.name, rule.TimeOfDay)
+		}
+		c.windowStart, c.windowEnd = start, end
+	}
+	return c, nil
+}
+
+// parseClockMinutes parses "HH:MM" into minutes after midnight.
+func parseClockMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func lowerAll(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// EvaluateReceivePolicy returns the decision of the first rule matching request from remoteAddr, or false when
+// no rule matches. verifySender proves the claimed fingerprint (see transfer.VerifySender); it is only called
+// when a rule depends on it: favorite conditions, and fingerprint conditions of accepting rules.
+func EvaluateReceivePolicy(request *types.PrepareUploadRequest, remoteAddr string, verifySender func() bool) (types.ReceivePolicyDecision, bool) {
+	receivePolicyMu.RLock()
+	rules := receivePolicy
+	receivePolicyMu.RUnlock()
+	if len(rules) == 0 || request == nil {
+		return types.ReceivePolicyDecision{}, false
+	}
+
+	sender, _ := netip.ParseAddr(remoteAddr)
+	sender = sender.WithZone("").Unmap()
+	var totalSize int64
+	fileIDs := make([]string, 0, len(request.Files))
+	for fileID, info := range request.Files {
+		totalSize += info.Size
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+	favorite := sync.OnceValue(func() bool {
+		return IsFavorite(request.Info.Fingerprint) && verifySender()
+	})
+	verified := sync.OnceValue(verifySender)
+	now := time.Now()
+	minute := now.Hour()*60 + now.Minute()
+
+	for _, rule := range rules {
+		if len(rule.Fingerprints) > 0 && !slices.Contains(rule.Fingerprints, request.Info.Fingerprint) {
+			continue
+		}
+		if len(rule.Aliases) > 0 && !matchAnyPattern(rule.Aliases, strings.ToLower(request.Info.Alias)) {
+			continue
+		}
+		if len(rule.senders) > 0 && !slices.ContainsFunc(rule.senders, func(p netip.Prefix) bool { return p.Contains(sender) }) {
+			continue
+		}
+		if rule.MinFiles > 0 && len(fileIDs) < rule.MinFiles {
+			continue
+		}
+		if rule.MaxFiles > 0 && len(fileIDs) > rule.MaxFiles {
+			continue
+		}
+		if rule.MinTotalSize > 0 && totalSize < rule.MinTotalSize {
+			continue
+		}
+		if rule.MaxTotalSize > 0 && totalSize > rule.MaxTotalSize {
+			continue
+		}
+		if rule.windowStart >= 0 && !inClockWindow(minute, rule.windowStart, rule.windowEnd) {
+			continue
+		}
+
+		var matched []string
+		for _, fileID := range fileIDs {
+			if rule.matchesFile(request.Files[fileID]) {
+				matched = append(matched, fileID)
+			}
+		}
+		if rule.Action == types.ReceiveActionAcceptSubset {
+			if len(matched) == 0 {
+				continue
+			}
+		} else if len(matched) != len(fileIDs) {
+			continue
+		}
+
+		// Checked last: these may ask the sender to prove its fingerprint over the network.
+		if rule.Favorite != nil && favorite() != *rule.Favorite {
+			continue
+		}
+		accepting := rule.Action == types.ReceiveActionAccept || rule.Action == types.ReceiveActionAcceptSubset
+		if accepting && len(rule.Fingerprints) > 0 && !verified() {
+			DefaultLogger.Warnf("Receive policy rule %s skipped: fingerprint %s of %s not verified",
+				rule.name, request.Info.Fingerprint, remoteAddr)
+			continue
+		}
+
+		decision := types.ReceivePolicyDecision{Action: rule.Action, Rule: rule.name}
+		if rule.Action == types.ReceiveActionAcceptSubset {
+			decision.AcceptedFiles = matched
+		}
+		return decision, true
+	}
+	return types.ReceivePolicyDecision{}, false
+}
+
+// matchesFile reports whether info passes the rule's mimeTypes and extensions conditions.
+func (rule *receivePolicyRule) matchesFile(info types.FileInfo) bool {
+	if len(rule.MimeTypes) > 0 && !matchAnyPattern(rule.MimeTypes, strings.ToLower(strings.TrimSpace(info.FileType))) {
+		return false
+	}
+	if len(rule.Extensions) > 0 && !slices.Contains(rule.Extensions, strings.ToLower(filepath.Ext(info.FileName))) {
+		return false
+	}
+	return true
+}
+
+func matchAnyPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inClockWindow reports whether minute lies in [start, end), wrapping midnight when end <= start.
+func inClockWindow(minute, start, end int) bool {
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
@@ -0,0 +1,151 @@
+package tool
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestCompileReceivePolicyRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      types.ReceivePolicyRule
+		valid     bool
+		wantStart int
+		wantEnd   int
+	}{
+		{name: "accept", rule: types.ReceivePolicyRule{Action: "Accept"}, valid: true, wantStart: -1, wantEnd: -1},
+		{name: "unknown action", rule: types.ReceivePolicyRule{Action: "maybe"}},
+		{name: "subset without files", rule: types.ReceivePolicyRule{Action: "accept-subset"}},
+		{name: "subset", rule: types.ReceivePolicyRule{Action: "accept-subset", Extensions: []string{"jpg"}}, valid: true, wantStart: -1, wantEnd: -1},
+		{name: "ip with zone", rule: types.ReceivePolicyRule{Action: "reject", Senders: []string{"fe80::1%eth0"}}, valid: true, wantStart: -1, wantEnd: -1},
+		{name: "cidr with zone", rule: types.ReceivePolicyRule{Action: "reject", Senders: []string{"fe80::%eth0/64"}}},
+		{name: "bad cidr", rule: types.ReceivePolicyRule{Action: "reject", Senders: []string{"10.0.0.0/33"}}},
+		{name: "bad ip", rule: types.ReceivePolicyRule{Action: "reject", Senders: []string{"10.0.0"}}},
+		{name: "bad alias pattern", rule: types.ReceivePolicyRule{Action: "ask", Aliases: []string{"[a"}}},
+		{name: "day window", rule: types.ReceivePolicyRule{Action: "accept", TimeOfDay: "08:00-18:30"}, valid: true, wantStart: 480, wantEnd: 1110},
+		{name: "night window", rule: types.ReceivePolicyRule{Action: "reject", TimeOfDay: "22:00-06:00"}, valid: true, wantStart: 1320, wantEnd: 360},
+		{name: "bad window", rule: types.ReceivePolicyRule{Action: "reject", TimeOfDay: "22:00"}},
+		{name: "bad clock", rule: types.ReceivePolicyRule{Action: "reject", TimeOfDay: "25:00-06:00"}},
+	}
+	for _, tt := range tests {
+		c, err := compileReceivePolicyRule(0, tt.rule)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: err = %v, want valid %v", tt.name, err, tt.valid)
+			continue
+		}
+		if tt.valid && (c.windowStart != tt.wantStart || c.windowEnd != tt.wantEnd) {
+			t.Errorf("%s: window = %d-%d, want %d-%d", tt.name, c.windowStart, c.windowEnd, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestInClockWindow(t *testing.T) {
+	day, night := [2]int{480, 1110}, [2]int{1320, 360} // 08:00-18:30, 22:00-06:00
+	tests := []struct {
+		window [2]int
+		minute int
+		want   bool
+	}{
+		{day, 479, false},
+		{day, 480, true},
+		{day, 1109, true},
+		{day, 1110, false},
+		{night, 1319, false},
+		{night, 1320, true},
+		{night, 0, true},
+		{night, 359, true},
+		{night, 360, false},
+		{night, 720, false},
+	}
+	for _, tt := range tests {
+		if got := inClockWindow(tt.minute, tt.window[0], tt.window[1]); got != tt.want {
+			t.Errorf("inClockWindow(%d, %d, %d) = %v, want %v", tt.minute, tt.window[0], tt.window[1], got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateReceivePolicy(t *testing.T) {
+	t.Cleanup(func() { _ = SetReceivePolicy(nil) })
+	err := SetReceivePolicy([]types.ReceivePolicyRule{
+		{Name: "blocked", Senders: []string{"10.0.0.66"}, Action: "reject"},
+		{Name: "trusted", Fingerprints: []string{"trusted-fp"}, Action: "accept"},
+		{Name: "lan", Senders: []string{"192.168.1.0/24", "::ffff:172.16.0.0/108"}, MaxFiles: 2, Action: "accept"},
+		{Name: "zoned", Senders: []string{"fe80::1%eth0"}, Action: "reject"},
+		{Name: "link-local", Senders: []string{"fe80::/10"}, Action: "ask"},
+		{Name: "photos", MimeTypes: []string{"image/*"}, Extensions: []string{"jpg", "png"}, Action: "accept-subset"},
+		{Name: "untrusted-reject", Fingerprints: []string{"other-fp"}, Action: "reject"},
+		{Name: "big", MinTotalSize: 1 << 30, Action: "reject"},
+	})
+	if err != nil {
+		t.Fatalf("SetReceivePolicy: %v", err)
+	}
+	text := types.FileInfo{FileName: "a.txt", FileType: "text/plain", Size: 10}
+	jpg := types.FileInfo{FileName: "b.JPG", FileType: "image/jpeg", Size: 10}
+	png := types.FileInfo{FileName: "c.png", FileType: "image/png", Size: 10}
+	huge := types.FileInfo{FileName: "d.iso", FileType: "application/octet-stream", Size: 2 << 30}
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		ip          string
+		files       map[string]types.FileInfo
+		verified    bool
+		wantRule    string // "" = no rule matches
+		wantAction  string
+		wantFiles   []string
+		wantVerify  bool // verifySender must be called
+	}{
+		{name: "first match wins", fingerprint: "trusted-fp", ip: "10.0.0.66", files: map[string]types.FileInfo{"1": text},
+			verified: true, wantRule: "blocked", wantAction: "reject"},
+		{name: "verified fingerprint", fingerprint: "trusted-fp", ip: "10.0.0.1", files: map[string]types.FileInfo{"1": text},
+			verified: true, wantRule: "trusted", wantAction: "accept", wantVerify: true},
+		{name: "unverified fingerprint skipped", fingerprint: "trusted-fp", ip: "10.0.0.1", files: map[string]types.FileInfo{"1": jpg, "2": text},
+			wantRule: "photos", wantAction: "accept-subset", wantFiles: []string{"1"}, wantVerify: true},
+		{name: "cidr", ip: "192.168.1.20", files: map[string]types.FileInfo{"1": text},
+			wantRule: "lan", wantAction: "accept"},
+		{name: "ipv4-mapped sender", ip: "::ffff:192.168.1.20", files: map[string]types.FileInfo{"1": text},
+			wantRule: "lan", wantAction: "accept"},
+		{name: "ipv4-mapped cidr", ip: "172.16.3.4", files: map[string]types.FileInfo{"1": text},
+			wantRule: "lan", wantAction: "accept"},
+		{name: "too many files", ip: "192.168.1.20", files: map[string]types.FileInfo{"1": text, "2": text, "3": text},
+			wantRule: ""},
+		{name: "zone ignored", ip: "fe80::1%wlan0", files: map[string]types.FileInfo{"1": text},
+			wantRule: "zoned", wantAction: "reject"},
+		{name: "zoned sender in cidr", ip: "fe80::2%eth0", files: map[string]types.FileInfo{"1": text},
+			wantRule: "link-local", wantAction: "ask"},
+		{name: "accept-subset", ip: "10.0.0.1", files: map[string]types.FileInfo{"a": png, "b": text, "c": jpg},
+			wantRule: "photos", wantAction: "accept-subset", wantFiles: []string{"a", "c"}},
+		{name: "reject needs no verification", fingerprint: "other-fp", ip: "10.0.0.1", files: map[string]types.FileInfo{"1": text},
+			wantRule: "untrusted-reject", wantAction: "reject"},
+		{name: "total size", ip: "10.0.0.1", files: map[string]types.FileInfo{"1": huge},
+			wantRule: "big", wantAction: "reject"},
+		{name: "no match", ip: "10.0.0.1", files: map[string]types.FileInfo{"1": text}, wantRule: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &types.PrepareUploadRequest{
+				Info:  types.DeviceInfo{Alias: "Phone", Fingerprint: tt.fingerprint},
+				Files: tt.files,
+			}
+			called := false
+			decision, ok := EvaluateReceivePolicy(request, tt.ip, func() bool {
+				called = true
+				return tt.verified
+			})
+			if ok != (tt.wantRule != "") {
+				t.Fatalf("matched = %v (rule %q), want rule %q", ok, decision.Rule, tt.wantRule)
+			}
+			if decision.Rule != tt.wantRule || decision.Action != tt.wantAction {
+				t.Errorf("decision = %s by %q, want %s by %q", decision.Action, decision.Rule, tt.wantAction, tt.wantRule)
+			}
+			if !slices.Equal(decision.AcceptedFiles, tt.wantFiles) {
+				t.Errorf("accepted files = %v, want %v", decision.AcceptedFiles, tt.wantFiles)
+			}
+			if called != tt.wantVerify {
+				t.Errorf("verifySender called = %v, want %v", called, tt.wantVerify)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// defaultWarnBelowBytes is the free space under which confirm_recv carries a warning.
+const defaultWarnBelowBytes = 1 << 30
+
+var (
+	receiveQuotaMu  sync.Mutex
+	receiveQuota    types.ReceiveQuotaConfig
+	receivedDay     string           // YYYY-MM-DD of the counters below
+	receivedToday   int64            // bytes received today from all senders
+	receivedSenders map[string]int64 // bytes received today per sender (verified fingerprint or IP address)
+	// receiveReservations holds the bytes accepted but not yet received per admitted session, so concurrent
+	// sessions cannot all pass the quota and free-space checks with the same bytes
+	receiveReservations = make(map[string]*receiveReservation)
+)
+
+type receiveReservation struct {
+	sender string
+	files  map[string]reservedFile // accepted files not yet received, by file ID
+}
+
+// reservedFile is the space an accepted file will take on the file system fs (see fileSystemID).
+type reservedFile struct {
+	fs    string
+	bytes int64
+}
+
+// SetReceiveQuota validates cfg and makes it the active free-space and quota configuration.
+func SetReceiveQuota(cfg types.ReceiveQuotaConfig) error {
+	cfg.OnExceed = strings.ToLower(strings.TrimSpace(cfg.OnExceed))
+	switch cfg.OnExceed {
+	case "":
+		cfg.OnExceed = "reject"
+	case "reject", "partial":
+	default:
+		return fmt.Errorf("receive quota: invalid onExceed %q (use reject or partial)", cfg.OnExceed)
+	}
+	if cfg.ReserveBytes < 0 || cfg.WarnBelowBytes < 0 || cfg.SenderDailyBytes < 0 || cfg.DailyBytes < 0 {
+		return fmt.Errorf("receive quota: sizes must not be negative")
+	}
+	if cfg.WarnBelowBytes == 0 {
+		cfg.WarnBelowBytes = defaultWarnBelowBytes
+	}
+	receiveQuotaMu.Lock()
+	receiveQuota = cfg
+	receiveQuotaMu.Unlock()
+	return nil
+}
+
+// rollReceivedDayLocked resets the daily counters after midnight. receiveQuotaMu must be held.
+func rollReceivedDayLocked() {
+	today := time.Now().Format("2006-01-02")
+	if receivedDay != today {
+		receivedDay = today
+		receivedToday = 0
+		receivedSenders = make(map[string]int64)
+	}
+}
+
+// HasSenderQuota reports whether a per-sender daily quota is configured.
+func HasSenderQuota() bool {
+	receiveQuotaMu.Lock()
+	defer receiveQuotaMu.Unlock()
+	return receiveQuota.SenderDailyBytes > 0
+}
+
+// RecordReceivedBytes moves the n received bytes of a file from the reservation of its session to today's quota
+// counters; the file now takes the space it reserved.
+func RecordReceivedBytes(sessionId, fileID string, n int64) {
+	receiveQuotaMu.Lock()
+	defer receiveQuotaMu.Unlock()
+	rollReceivedDayLocked()
+	receivedToday += n
+	if r := receiveReservations[sessionId]; r != nil {
+		delete(r.files, fileID)
+		receivedSenders[r.sender] += n
+	}
+}
+
+// ShrinkReceiveReservation keeps the reservation of a session for fileIDs only, e.g. once the user accepted
+// some of the files.
+func ShrinkReceiveReservation(sessionId string, fileIDs []string) {
+	receiveQuotaMu.Lock()
+	defer receiveQuotaMu.Unlock()
+	r := receiveReservations[sessionId]
+	if r == nil {
+		return
+	}
+	kept := make(map[string]reservedFile, len(fileIDs))
+	for _, fileID := range fileIDs {
+		if f, ok := r.files[fileID]; ok {
+			kept[fileID] = f
+		}
+	}
+	r.files = kept
+}
+
+// ReleaseReceiveReservation drops the bytes a session reserved but did not receive. Releasing twice is harmless.
+func ReleaseReceiveReservation(sessionId string) {
+	receiveQuotaMu.Lock()
+	defer receiveQuotaMu.Unlock()
+	delete(receiveReservations, sessionId)
+}
+
+// reservedBytesLocked returns the bytes reserved by all sessions, by the sessions of sender and per file system.
+// receiveQuotaMu must be held.
+func reservedBytesLocked(sender string) (total, bySender int64, byFS map[string]int64) {
+	byFS = make(map[string]int64)
+	for _, r := range receiveReservations {
+		for _, f := range r.files {
+			total += f.bytes
+			if r.sender == sender {
+				bySender += f.bytes
+			}
+			byFS[f.fs] += f.bytes
+		}
+	}
+	return total, bySender, byFS
+}
+
+// CheckReceiveStorage decides which of files fit the free space of their destination roots (fileID -> root
+// folder) minus the reserve and the space reserved by other sessions, and today's quotas of sender (a verified
+// fingerprint or the IP address). It returns the IDs of the files to accept and a warning for the confirm dialog
+// when space is tight or only some files fit. When nothing fits, or not all files fit and onExceed is reject, it
+// fails with "insufficient storage" or "quota exceeded". The accepted bytes are reserved for sessionId until they
+// are received or ReleaseReceiveReservation.
+func CheckReceiveStorage(sessionId string, files map[string]types.FileInfo, roots map[string]string, sender string) ([]string, string, error) {
+	receiveQuotaMu.Lock()
+	cfg := receiveQuota
+	receiveQuotaMu.Unlock()
+
+	// Free space per file system, which roots may share; roots whose file system cannot be queried are not limited.
+	rootFS := make(map[string]string)
+	fsRoot := make(map[string]string) // a root on each file system, for warnings
+	free := make(map[string]int64)
+	for _, root := range roots {
+		if _, ok := rootFS[root]; ok {
+			continue
+		}
+		dir := nearestExistingDir(root)
+		fs, err := fileSystemID(dir)
+		if err != nil {
+			DefaultLogger.Debugf("File system of %s unknown, not checked: %v", root, err)
+			continue
+		}
+		rootFS[root] = fs
+		if _, ok := free[fs]; ok {
+			continue
+		}
+		bytes, err := diskFreeBytes(dir)
+		if err != nil {
+			DefaultLogger.Debugf("Free space of %s unknown, not checked: %v", root, err)
+			continue
+		}
+		fsRoot[fs] = root
+		free[fs] = int64(min(bytes, uint64(1<<62)))
+	}
+
+	ids := make([]string, 0, len(files))
+	for fileID := range files {
+		ids = append(ids, fileID)
+	}
+	sort.Strings(ids)
+
+	// The quotas are checked and reserved in one go, so concurrent sessions see each other's reservations.
+	receiveQuotaMu.Lock()
+	defer receiveQuotaMu.Unlock()
+	rollReceivedDayLocked()
+	reserved, reservedBySender, reservedByFS := reservedBytesLocked(sender)
+	// Space left per file system after keeping the reserve and the space other sessions will take.
+	spaceLeft := make(map[string]int64, len(free))
+	for fs, bytes := range free {
+		spaceLeft[fs] = bytes - reservedByFS[fs] - cfg.ReserveBytes
+	}
+	quotaLeft := int64(-1) // -1 = unlimited
+	if cfg.DailyBytes > 0 {
+		quotaLeft = max(cfg.DailyBytes-receivedToday-reserved, 0)
+	}
+	if cfg.SenderDailyBytes > 0 {
+		senderLeft := max(cfg.SenderDailyBytes-receivedSenders[sender]-reservedBySender, 0)
+		if quotaLeft < 0 || senderLeft < quotaLeft {
+			quotaLeft = senderLeft
+		}
+	}
+	accepted := make([]string, 0, len(ids))
+	reservation := &receiveReservation{sender: sender, files: make(map[string]reservedFile, len(ids))}
+	var noSpace bool
+	for _, fileID := range ids {
+		size := max(files[fileID].Size, 0)
+		fs := rootFS[roots[fileID]]
+		left, limited := spaceLeft[fs]
+		if limited && size > left {
+			noSpace = true
+			continue
+		}
+		if quotaLeft >= 0 && size > quotaLeft {
+			continue
+		}
+		if limited {
+			spaceLeft[fs] -= size
+		}
+		if quotaLeft >= 0 {
+			quotaLeft -= size
+		}
+		reservation.files[fileID] = reservedFile{fs: fs, bytes: size}
+		accepted = append(accepted, fileID)
+	}
+
+	if len(accepted) < len(ids) {
+		reason := "quota exceeded"
+		if noSpace {
+			reason = "insufficient storage"
+		}
+		if len(accepted) == 0 || cfg.OnExceed != "partial" {
+			DefaultLogger.Warnf("Rejecting %d files from %s: %s", len(ids), sender, reason)
+			return nil, "", fmt.Errorf("%s", reason)
+		}
+		DefaultLogger.Warnf("Accepting %d of %d files from %s: %s", len(accepted), len(ids), sender, reason)
+		receiveReservations[sessionId] = reservation
+		return accepted, fmt.Sprintf("Only %d of %d files accepted: %s", len(accepted), len(ids), reason), nil
+	}
+
+	var warnings []string
+	for fs, left := range spaceLeft {
+		if left+cfg.ReserveBytes < cfg.WarnBelowBytes {
+			warnings = append(warnings, fmt.Sprintf("low disk space in %s: %d MiB left after this transfer", fsRoot[fs], (left+cfg.ReserveBytes)>>20))
+		}
+	}
+	sort.Strings(warnings)
+	receiveReservations[sessionId] = reservation
+	return accepted, strings.Join(warnings, "; "), nil
+}
+
+// nearestExistingDir returns dir or its closest existing parent, since destination folders are created lazily.
+func nearestExistingDir(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "."
+	}
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,209 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// defaultReceivePathTemplate keeps every session in its own folder, as before routing existed.
+const defaultReceivePathTemplate = "{session}"
+
+var receivePathPlaceholders = []string{"{sender}", "{fingerprint}", "{ip}", "{date}", "{session}"}
+
+// receiveRoute is a validated ReceiveRoute.
+type receiveRoute struct {
+	types.ReceiveRoute
+	name string
+}
+
+var (
+	receiveRoutingMu   sync.RWMutex
+	receivePathDefault string
+	receiveRoutes      []receiveRoute
+)
+
+// SetReceiveRouting validates cfg and makes it the active destination routing. On error the routing is unchanged.
+func SetReceiveRouting(cfg types.ReceiveRoutingConfig) error {
+	if err := validateReceivePathTemplate(cfg.Path); err != nil {
+		return fmt.Errorf("receive routing: %v", err)
+	}
+	routes := make([]receiveRoute, 0, len(cfg.Routes))
+	for i, route := range cfg.Routes {
+		r := receiveRoute{ReceiveRoute: route, name: route.Name}
+		if r.name == "" {
+			r.name = fmt.Sprintf("#%d", i+1)
+		}
+		if err := validateReceivePathTemplate(route.Path); err != nil {
+			return fmt.Errorf("receive route %s: %v", r.name, err)
+		}
+		dir, err := expandHomeDir(strings.TrimSpace(route.Dir))
+		if err != nil {
+			return fmt.Errorf("receive route %s: %v", r.name, err)
+		}
+		r.Dir = dir
+		r.MimeTypes = lowerAll(route.MimeTypes)
+		for _, pattern := range r.MimeTypes {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("receive route %s: invalid mime type pattern %q", r.name, pattern)
+			}
+		}
+		r.Extensions = make([]string, 0, len(route.Extensions))
+		for _, ext := range lowerAll(route.Extensions) {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			r.Extensions = append(r.Extensions, ext)
+		}
+		routes = append(routes, r)
+	}
+	receiveRoutingMu.Lock()
+	receivePathDefault = cfg.Path
+	receiveRoutes = routes
+	receiveRoutingMu.Unlock()
+	if len(routes) > 0 {
+		DefaultLogger.Infof("Receive routing: %d routes loaded", len(routes))
+	}
+	return nil
+}
+
+// ListReceiveRoots returns the extra root folders of the configured routes (the upload folder excluded).
+func ListReceiveRoots() []string {
+	receiveRoutingMu.RLock()
+	defer receiveRoutingMu.RUnlock()
+	roots := make([]string, 0, len(receiveRoutes))
+	for _, route := range receiveRoutes {
+		if route.Dir != "" && !slices.Contains(roots, route.Dir) {
+			roots = append(roots, route.Dir)
+		}
+	}
+	return roots
+}
+
+// ResolveReceiveDestination returns the folder for info sent by sender in sessionId. uploadFolder is the root
+// when no route sets one; doNotMakeSessionFolder changes the default template from {session} to the root itself.
+// favorite reports whether the sender is a verified favorite and is only called when a route depends on it.
+func ResolveReceiveDestination(info types.FileInfo, sender types.DeviceInfo, remoteAddr, sessionId, uploadFolder string,
+	doNotMakeSessionFolder bool, favorite func() bool) types.ReceiveDestination {
+	receiveRoutingMu.RLock()
+	template := receivePathDefault
+	routes := receiveRoutes
+	receiveRoutingMu.RUnlock()
+	if template == "" {
+		template = defaultReceivePathTemplate
+		if doNotMakeSessionFolder {
+			template = "."
+		}
+	}
+
+	root, routeName := uploadFolder, "default"
+	for _, route := range routes {
+		if !route.matches(info, sender, favorite) {
+			continue
+		}
+		if route.Dir != "" {
+			root = route.Dir
+		}
+		if route.Path != "" {
+			template = route.Path
+		}
+		routeName = route.name
+		break
+	}
+
+	// One pass, so a value containing a placeholder (e.g. an alias "{session}") is not expanded again.
+	rendered := strings.NewReplacer(
+		"{sender}", sanitizePathSegment(sender.Alias),
+		"{fingerprint}", sanitizePathSegment(sender.Fingerprint),
+		"{ip}", sanitizePathSegment(remoteAddr),
+		"{date}", time.Now().Format("2006-01-02"),
+		"{session}", sanitizePathSegment(sessionId),
+	).Replace(template)
+	rendered = filepath.Clean(filepath.FromSlash(rendered))
+	if !filepath.IsLocal(rendered) && rendered != "." {
+		// Unreachable with validated templates and sanitized values; never leave the root.
+		DefaultLogger.Warnf("Receive routing: %q escapes %s, saving to the root instead", rendered, root)
+		rendered = "."
+	}
+	return types.ReceiveDestination{
+		Root:          root,
+		Dir:           filepath.Join(root, rendered),
+		Route:         routeName,
+		SessionUnique: strings.Contains(template, "{session}"),
+	}
+}
+
+// matches reports whether the route applies to info from sender.
+func (route *receiveRoute) matches(info types.FileInfo, sender types.DeviceInfo, favorite func() bool) bool {
+	if len(route.MimeTypes) > 0 && !matchAnyPattern(route.MimeTypes, strings.ToLower(strings.TrimSpace(info.FileType))) {
+		return false
+	}
+	if len(route.Extensions) > 0 && !slices.Contains(route.Extensions, strings.ToLower(filepath.Ext(info.FileName))) {
+		return false
+	}
+	if len(route.Fingerprints) > 0 && !slices.Contains(route.Fingerprints, sender.Fingerprint) {
+		return false
+	}
+	// A claimed fingerprint alone could steer files into another device's folder, so it must be a verified favorite.
+	if len(route.Fingerprints) > 0 && !favorite() {
+		return false
+	}
+	if route.Favorite != nil && favorite() != *route.Favorite {
+		return false
+	}
+	return true
+}
+
+// validateReceivePathTemplate rejects templates with unknown placeholders or that could leave their root.
+func validateReceivePathTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	rendered := template
+	for _, placeholder := range receivePathPlaceholders {
+		rendered = strings.ReplaceAll(rendered, placeholder, "x")
+	}
+	if strings.ContainsAny(rendered, "{}") {
+		return fmt.Errorf("unknown placeholder in path template %q (use %s)", template, strings.Join(receivePathPlaceholders, ", "))
+	}
+	rendered = filepath.Clean(filepath.FromSlash(rendered))
+	if rendered != "." && !filepath.IsLocal(rendered) {
+		return fmt.Errorf("path template %q must be relative and stay inside its folder", template)
+	}
+	return nil
+}
+
+// sanitizePathSegment turns s into a single safe path element: separators and characters invalid on
+// common file systems become "_", and "", "." and ".." become "_".
+func sanitizePathSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+	s = strings.Trim(s, ". ")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// expandHomeDir replaces a leading ~ with the user's home folder.
+func expandHomeDir(dir string) (string, error) {
+	if dir != "~" && !strings.HasPrefix(dir, "~/") && !strings.HasPrefix(dir, `~\`) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot expand %q: %v", dir, err)
+	}
+	return filepath.Join(home, dir[1:]), nil
+}
@@ -0,0 +1,161 @@
+package tool
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestValidateReceivePathTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{"", true},
+		{".", true},
+		{"{session}", true},
+		{"{sender}/{date}", true},
+		{"photos/{fingerprint}/{ip}", true},
+		{"a/../b", true},
+		{"{unknown}", false},
+		{"{sender", false},
+		{"..", false},
+		{"../{session}", false},
+		{"{sender}/../../x", false},
+		{"/abs/{session}", false},
+	}
+	for _, tt := range tests {
+		err := validateReceivePathTemplate(tt.template)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateReceivePathTemplate(%q) = %v, want valid %v", tt.template, err, tt.valid)
+		}
+	}
+}
+
+func TestResolveReceiveDestination(t *testing.T) {
+	t.Cleanup(func() { _ = SetReceiveRouting(types.ReceiveRoutingConfig{}) })
+	favorite := true
+	err := SetReceiveRouting(types.ReceiveRoutingConfig{
+		Path: "{sender}/{session}",
+		Routes: []types.ReceiveRoute{
+			{Name: "images", MimeTypes: []string{"image/*"}, Dir: "/pictures", Path: "{date}"},
+			{Name: "apks", Extensions: []string{"apk"}, Path: "."},
+			{Name: "favorites", Favorite: &favorite, Path: "{fingerprint}/{ip}"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetReceiveRouting: %v", err)
+	}
+	date := time.Now().Format("2006-01-02")
+	root := filepath.FromSlash("/uploads")
+
+	tests := []struct {
+		name      string
+		info      types.FileInfo
+		sender    types.DeviceInfo
+		ip        string
+		session   string
+		favorite  bool
+		wantDir   string
+		wantRoute string
+		unique    bool
+	}{
+		{
+			name:      "default template",
+			info:      types.FileInfo{FileName: "notes.txt", FileType: "text/plain"},
+			sender:    types.DeviceInfo{Alias: "Phone"},
+			session:   "s1",
+			wantDir:   filepath.Join(root, "Phone", "s1"),
+			wantRoute: "default",
+			unique:    true,
+		},
+		{
+			name:      "first match wins",
+			info:      types.FileInfo{FileName: "a.png", FileType: "image/png"},
+			sender:    types.DeviceInfo{Alias: "Phone"},
+			session:   "s1",
+			favorite:  true,
+			wantDir:   filepath.Join(filepath.FromSlash("/pictures"), date),
+			wantRoute: "images",
+		},
+		{
+			name:      "extension route",
+			info:      types.FileInfo{FileName: "App.APK", FileType: "application/octet-stream"},
+			sender:    types.DeviceInfo{Alias: "Phone"},
+			session:   "s1",
+			wantDir:   root,
+			wantRoute: "apks",
+		},
+		{
+			name:      "verified favorite",
+			info:      types.FileInfo{FileName: "doc.pdf", FileType: "application/pdf"},
+			sender:    types.DeviceInfo{Alias: "Phone", Fingerprint: "abc"},
+			ip:        "fe80::1%eth0",
+			session:   "s1",
+			favorite:  true,
+			wantDir:   filepath.Join(root, "abc", "fe80__1%eth0"),
+			wantRoute: "favorites",
+		},
+		{
+			name:      "traversal in alias",
+			info:      types.FileInfo{FileName: "doc.pdf"},
+			sender:    types.DeviceInfo{Alias: "../../etc"},
+			session:   "s1",
+			wantDir:   filepath.Join(root, "_.._etc", "s1"),
+			wantRoute: "default",
+			unique:    true,
+		},
+		{
+			name:      "dot alias",
+			info:      types.FileInfo{FileName: "doc.pdf"},
+			sender:    types.DeviceInfo{Alias: ".."},
+			session:   "..",
+			wantDir:   filepath.Join(root, "_", "_"),
+			wantRoute: "default",
+			unique:    true,
+		},
+		{
+			name:      "placeholder in alias is not expanded",
+			info:      types.FileInfo{FileName: "doc.pdf"},
+			sender:    types.DeviceInfo{Alias: "{session}"},
+			session:   "s1",
+			wantDir:   filepath.Join(root, "{session}", "s1"),
+			wantRoute: "default",
+			unique:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolveReceiveDestination(tt.info, tt.sender, tt.ip, tt.session, root, false,
+				func() bool { return tt.favorite })
+			if got.Dir != tt.wantDir || got.Route != tt.wantRoute || got.SessionUnique != tt.unique {
+				t.Errorf("got dir %q route %q unique %v, want dir %q route %q unique %v",
+					got.Dir, got.Route, got.SessionUnique, tt.wantDir, tt.wantRoute, tt.unique)
+			}
+			rel, err := filepath.Rel(got.Root, got.Dir)
+			if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+				t.Errorf("dir %q leaves root %q", got.Dir, got.Root)
+			}
+		})
+	}
+}
+
+func TestResolveReceiveDestinationDefaults(t *testing.T) {
+	t.Cleanup(func() { _ = SetReceiveRouting(types.ReceiveRoutingConfig{}) })
+	if err := SetReceiveRouting(types.ReceiveRoutingConfig{}); err != nil {
+		t.Fatalf("SetReceiveRouting: %v", err)
+	}
+	root := filepath.FromSlash("/uploads")
+	never := func() bool { return false }
+
+	got := ResolveReceiveDestination(types.FileInfo{FileName: "a"}, types.DeviceInfo{}, "", "s1", root, false, never)
+	if want := filepath.Join(root, "s1"); got.Dir != want || !got.SessionUnique {
+		t.Errorf("session folder: got %q unique %v, want %q unique true", got.Dir, got.SessionUnique, want)
+	}
+	got = ResolveReceiveDestination(types.FileInfo{FileName: "a"}, types.DeviceInfo{}, "", "s1", root, true, never)
+	if got.Dir != root || got.SessionUnique {
+		t.Errorf("doNotMakeSessionFolder: got %q unique %v, want %q unique false", got.Dir, got.SessionUnique, root)
+	}
+}
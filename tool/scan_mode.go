@@ -0,0 +1,24 @@
+package tool
+
+import "github.com/moyoez/localsend-go/types"
+
+// ResolveScanMode picks the startup scan mode: -scanMode, then -useMDNS, then the scanMode config key, then mixed.
+func ResolveScanMode(flags types.Config, cfg types.AppConfig) (types.ScanMode, error) {
+	switch {
+	case flags.ScanMode != "":
+		return types.ParseScanMode(flags.ScanMode)
+	case flags.UseMDNS:
+		return types.ScanModeMDNS, nil
+	case cfg.ScanMode != "":
+		return types.ParseScanMode(cfg.ScanMode)
+	}
+	return types.ScanModeMixed, nil
+}
+
+// SetScanModeConfig writes the scan mode to the config file so it survives a restart (unless -scanMode overrides it).
+func SetScanModeConfig(mode types.ScanMode) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	CurrentConfig.ScanMode = mode.String()
+	return writeDefaultConfig(ConfigPath, CurrentConfig)
+}
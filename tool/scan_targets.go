@@ -0,0 +1,130 @@
+package tool
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// MaxScanTargetHosts caps the addresses of a single scan target (a /16) so a typo cannot queue a sweep of the whole internet.
+const MaxScanTargetHosts = 1 << 16
+
+// ParseScanTarget parses a CIDR (10.0.0.0/22), a range (10.0.0.10-10.0.3.200 or 10.0.0.10-200)
+// or a single IPv4 address and returns the first and last address as uint32.
+// For CIDRs shorter than /31 the network and broadcast addresses are excluded.
+func ParseScanTarget(spec string) (uint32, uint32, error) {
+	spec = strings.TrimSpace(spec)
+	var first, last uint32
+	switch {
+	case strings.Contains(spec, "/"):
+		prefix, err := netip.ParsePrefix(spec)
+		if err != nil || !prefix.Addr().Is4() {
+			return 0, 0, fmt.Errorf("invalid IPv4 CIDR: %q", spec)
+		}
+		prefix = prefix.Masked()
+		hostBits := 32 - prefix.Bits()
+		first = ipv4ToUint32(prefix.Addr())
+		last = first + uint32(uint64(1)<<hostBits-1)
+		if hostBits >= 2 {
+			first++
+			last--
+		}
+	case strings.Contains(spec, "-"):
+		from, to, _ := strings.Cut(spec, "-")
+		start, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil || !start.Is4() {
+			return 0, 0, fmt.Errorf("invalid IPv4 range start: %q", spec)
+		}
+		to = strings.TrimSpace(to)
+		if !strings.Contains(to, ".") {
+			// Short form: 10.0.0.10-200 keeps the first three octets.
+			octets := start.As4()
+			to = fmt.Sprintf("%d.%d.%d.%s", octets[0], octets[1], octets[2], to)
+		}
+		end, err := netip.ParseAddr(to)
+		if err != nil || !end.Is4() {
+			return 0, 0, fmt.Errorf("invalid IPv4 range end: %q", spec)
+		}
+		first, last = ipv4ToUint32(start), ipv4ToUint32(end)
+		if first > last {
+			return 0, 0, fmt.Errorf("invalid IPv4 range (start after end): %q", spec)
+		}
+	default:
+		addr, err := netip.ParseAddr(spec)
+		if err != nil || !addr.Is4() {
+			return 0, 0, fmt.Errorf("invalid IPv4 address: %q", spec)
+		}
+		first = ipv4ToUint32(addr)
+		last = first
+	}
+	if uint64(last)-uint64(first)+1 > MaxScanTargetHosts {
+		return 0, 0, fmt.Errorf("scan target %q is larger than %d addresses", spec, MaxScanTargetHosts)
+	}
+	return first, last, nil
+}
+
+// ExpandScanTargets returns every address of specs in order, without duplicates.
+func ExpandScanTargets(specs []string) ([]string, error) {
+	seen := make(map[uint32]struct{})
+	var ips []string
+	for _, spec := range specs {
+		first, last, err := ParseScanTarget(spec)
+		if err != nil {
+			return nil, err
+		}
+		for n := uint64(first); n <= uint64(last); n++ {
+			if _, ok := seen[uint32(n)]; ok {
+				continue
+			}
+			seen[uint32(n)] = struct{}{}
+			ips = append(ips, uint32ToIPv4(uint32(n)).String())
+		}
+	}
+	return ips, nil
+}
+
+// ListScanTargets returns a copy of the configured HTTP scan targets.
+func ListScanTargets() []string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	result := make([]string, len(CurrentConfig.ScanTargets))
+	copy(result, CurrentConfig.ScanTargets)
+	return result
+}
+
+// SetScanTargets validates the HTTP scan targets, writes them to the config file and then makes them active.
+func SetScanTargets(specs []string) error {
+	cleaned := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if _, _, err := ParseScanTarget(spec); err != nil {
+			return err
+		}
+		cleaned = append(cleaned, spec)
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	// Applied only once written, so a failed write leaves the scan targets unchanged.
+	updated := CurrentConfig
+	updated.ScanTargets = cleaned
+	if err := writeDefaultConfig(ConfigPath, updated); err != nil {
+		return err
+	}
+	CurrentConfig.ScanTargets = cleaned
+	return nil
+}
+
+func ipv4ToUint32(addr netip.Addr) uint32 {
+	b := addr.As4()
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func uint32ToIPv4(n uint32) netip.Addr {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	return netip.AddrFrom4(b)
+}
@@ -0,0 +1,68 @@
+package tool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// NormalizeStaticPeerHost trims spaces and IPv6 brackets from a static peer host.
+func NormalizeStaticPeerHost(host string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(host), "["), "]")
+}
+
+// AddStaticPeer adds a static peer and returns the stored entry. An entry with the same host and port is replaced.
+func AddStaticPeer(entry types.StaticPeerEntry) (types.StaticPeerEntry, error) {
+	entry.Host = NormalizeStaticPeerHost(entry.Host)
+	entry.Fingerprint = strings.TrimSpace(entry.Fingerprint)
+	if entry.Host == "" {
+		return entry, fmt.Errorf("host is required")
+	}
+	if entry.Port < 0 || entry.Port > 65535 {
+		return entry, fmt.Errorf("invalid port: %d", entry.Port)
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	found := false
+	for i, peer := range CurrentConfig.StaticPeers {
+		if peer.Host == entry.Host && peer.Port == entry.Port {
+			CurrentConfig.StaticPeers[i] = entry
+			found = true
+			break
+		}
+	}
+	if !found {
+		CurrentConfig.StaticPeers = append(CurrentConfig.StaticPeers, entry)
+	}
+	return entry, writeDefaultConfig(ConfigPath, CurrentConfig)
+}
+
+// ListStaticPeers returns a copy of the configured static peers.
+func ListStaticPeers() []types.StaticPeerEntry {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	result := make([]types.StaticPeerEntry, len(CurrentConfig.StaticPeers))
+	copy(result, CurrentConfig.StaticPeers)
+	return result
+}
+
+// RemoveStaticPeer removes the static peer with host and port. Returns false if no entry matched.
+func RemoveStaticPeer(host string, port int) (bool, error) {
+	host = NormalizeStaticPeerHost(host)
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	newList := make([]types.StaticPeerEntry, 0, len(CurrentConfig.StaticPeers))
+	for _, peer := range CurrentConfig.StaticPeers {
+		if peer.Host != host || peer.Port != port {
+			newList = append(newList, peer)
+		}
+	}
+	if len(newList) == len(CurrentConfig.StaticPeers) {
+		return false, nil
+	}
+	CurrentConfig.StaticPeers = newList
+	return true, writeDefaultConfig(ConfigPath, CurrentConfig)
+}
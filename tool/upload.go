@@ -2,11 +2,15 @@ package tool
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 // NextAvailablePath returns the first path under dir that does not exist, using fileName
@@ -30,6 +34,34 @@ func NextAvailablePath(dir, fileName string) string {
 	}
 }
 
+// PartFileSuffix marks inbound files that are still being received.
+const PartFileSuffix = ".part"
+
+// PartFilePath returns the hidden .part file under dir for fileId of sessionId.
+// fileId is chosen by the sender, so it is hashed instead of used as a file name.
+func PartFilePath(dir, sessionId, fileId string) string {
+	sum := sha256.Sum256([]byte(fileId))
+	return filepath.Join(dir, "."+sessionId+"-"+hex.EncodeToString(sum[:8])+PartFileSuffix)
+}
+
+// IsPartFileName reports whether name has the form produced by PartFilePath, so unrelated files are never
+// taken for leftovers.
+func IsPartFileName(name string) bool {
+	if !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, PartFileSuffix) {
+		return false
+	}
+	core := strings.TrimSuffix(name[1:], PartFileSuffix)
+	idx := strings.LastIndexByte(core, '-')
+	if idx < 0 || len(core)-idx-1 != 16 {
+		return false
+	}
+	if _, err := hex.DecodeString(core[idx+1:]); err != nil {
+		return false
+	}
+	_, err := uuid.Parse(core[:idx])
+	return err == nil
+}
+
 // CopyWithContext copies from src to dst while respecting context cancellation.
 func CopyWithContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
 	buf := make([]byte, 2*1024*1024) // 2MB buffer
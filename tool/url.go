@@ -4,23 +4,40 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/moyoez/localsend-go/types"
 )
 
+// FormatURLHost joins host and port for use in a URL, bracketing IPv6 literals and escaping
+// the zone separator of link-local addresses (fe80::1%eth0 -> [fe80::1%25eth0]:53317).
+func FormatURLHost(host string, port int) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.Contains(host, ":") && !strings.Contains(host, "%25") {
+		host = strings.Replace(host, "%", "%25", 1)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// targetURLHost formats the host:port of targetAddr, keeping the zone of link-local IPv6 peers.
+func targetURLHost(targetAddr *net.UDPAddr, port int) string {
+	return FormatURLHost(IPStringWithZone(targetAddr.IP, targetAddr.Zone), port)
+}
+
 // BuildRegisterURL builds the /register callback URL
 func BuildRegisterURL(targetAddr *net.UDPAddr, remote *types.VersionMessage) (string, error) {
-	return fmt.Sprintf("%s://%s:%d/api/localsend/v2/register", remote.Protocol, targetAddr.IP.String(), remote.Port), nil
+	return fmt.Sprintf("%s://%s/api/localsend/v2/register", remote.Protocol, targetURLHost(targetAddr, remote.Port)), nil
 }
 
 func BuildScanOnceRegisterUrl(protocol string, targetIp string, port int) string {
-	return fmt.Sprintf("%s://%s:%d/api/localsend/v2/register", protocol, targetIp, port)
+	return fmt.Sprintf("%s://%s/api/localsend/v2/register", protocol, FormatURLHost(targetIp, port))
 }
 
 // BuildPrepareUploadURL builds the /prepare-upload URL.
 // If pin is not empty, add query parameter ?pin=xxx.
 func BuildPrepareUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, pin string) (string, error) {
-	url := fmt.Sprintf("%s://%s:%d/api/localsend/v2/prepare-upload", remote.Protocol, targetAddr.IP.String(), remote.Port)
+	url := fmt.Sprintf("%s://%s/api/localsend/v2/prepare-upload", remote.Protocol, targetURLHost(targetAddr, remote.Port))
 	if pin != "" {
 		url += fmt.Sprintf("?pin=%s", pin)
 	}
@@ -29,7 +46,7 @@ func BuildPrepareUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage
 
 // BuildUploadURL builds the /upload URL with sessionId, fileId, and token query parameters.
 func BuildUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string) (string, error) {
-	baseURL := fmt.Sprintf("%s://%s:%d/api/localsend/v2/upload", remote.Protocol, targetAddr.IP.String(), remote.Port)
+	baseURL := fmt.Sprintf("%s://%s/api/localsend/v2/upload", remote.Protocol, targetURLHost(targetAddr, remote.Port))
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse base URL: %v", err)
@@ -41,7 +58,7 @@ func BuildUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessi
 
 // BuildCancelURL builds the /cancel URL with sessionId query parameter.
 func BuildCancelURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId string) (string, error) {
-	baseURL := fmt.Sprintf("%s://%s:%d/api/localsend/v2/cancel", remote.Protocol, targetAddr.IP.String(), remote.Port)
+	baseURL := fmt.Sprintf("%s://%s/api/localsend/v2/cancel", remote.Protocol, targetURLHost(targetAddr, remote.Port))
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse base URL: %v", err)
@@ -53,5 +70,5 @@ func BuildCancelURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessi
 
 // BuildInfoURL builds the /info URL to get device information.
 func BuildInfoURL(protocol string, ip string, port int) string {
-	return fmt.Sprintf("%s://%s:%d/api/localsend/v2/info", protocol, ip, port)
+	return fmt.Sprintf("%s://%s/api/localsend/v2/info", protocol, FormatURLHost(ip, port))
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
@@ -44,6 +45,7 @@ func ReadyToUploadTo(targetAddr *net.UDPAddr, remote *types.VersionMessage, requ
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prepare-upload request: %v", err)
 	}
+	req = req.WithContext(tool.WithPeerFingerprint(req.Context(), remote.Fingerprint))
 	client := tool.GetHttpClient()
 	resp, err := client.Do(req)
 	if err != nil {
@@ -164,6 +166,10 @@ func FetchDeviceInfo(ip string, port int) (*types.CallbackLegacyVersionMessageHT
 			lastErr = fmt.Errorf("failed to parse info response: %v", err)
 			continue
 		}
+		// Do not fall back to http when the https certificate does not match the advertised fingerprint.
+		if err := tool.VerifyResponsePeerFingerprint(resp, deviceInfo.Fingerprint); err != nil {
+			return nil, "", err
+		}
 
 		tool.DefaultLogger.Infof("FetchDeviceInfo: successfully got device info from %s: %s (fingerprint: %s)",
 			url, deviceInfo.Alias, deviceInfo.Fingerprint)
@@ -172,3 +178,73 @@ func FetchDeviceInfo(ip string, port int) (*types.CallbackLegacyVersionMessageHT
 
 	return nil, "", fmt.Errorf("failed to fetch device info from %s:%d: %v", ip, port, lastErr)
 }
+
+// VerifySender checks that the device at ip answers /info with the fingerprint it claimed in a request,
+// over https with a certificate matching that fingerprint. A sender using http (e.g. in -useHttp mode) cannot
+// prove its fingerprint, so it is never verified, favorite or not.
+// ip must also be the address the fingerprint was discovered at (scan-current or the known-devices registry):
+// otherwise a relay on the sender's address could forward the check to the real device. The protocol has no
+// client certificates, so this stays best effort: a peer that can also fake discovery of the device at its own
+// address (answering discovery through the same relay) still passes.
+func VerifySender(ip string, info *types.DeviceInfo) error {
+	if info == nil || info.Fingerprint == "" {
+		return fmt.Errorf("sender did not report a fingerprint")
+	}
+	if !discoveredAt(info.Fingerprint, ip) {
+		return fmt.Errorf("fingerprint %s was not discovered at %s", info.Fingerprint, ip)
+	}
+	protocol := info.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+	if protocol != "https" {
+		return fmt.Errorf("sender uses %s and cannot prove its fingerprint", protocol)
+	}
+	port := info.Port
+	if port == 0 {
+		port = 53317
+	}
+	url := tool.BuildInfoURL(protocol, ip, port)
+	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("GET", url, nil))
+	if err != nil {
+		return fmt.Errorf("failed to create info request: %v", err)
+	}
+	req = req.WithContext(tool.WithPeerFingerprint(req.Context(), info.Fingerprint))
+	resp, err := tool.GetHttpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to verify sender at %s: %w", url, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to verify sender at %s: %s", url, resp.Status)
+	}
+	// Checked here too: the transport skips certificate checks with -insecureSkipPeerVerify.
+	if !tool.PeerResponseVerified(resp, info.Fingerprint) {
+		return fmt.Errorf("device at %s did not present a certificate matching fingerprint %s", url, info.Fingerprint)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return fmt.Errorf("failed to read info response body: %v", err)
+	}
+	var remote types.CallbackLegacyVersionMessageHTTP
+	if err := sonic.Unmarshal(body, &remote); err != nil {
+		return fmt.Errorf("failed to parse info response: %v", err)
+	}
+	if remote.Fingerprint != info.Fingerprint {
+		return fmt.Errorf("device at %s reports fingerprint %s, not %s", url, remote.Fingerprint, info.Fingerprint)
+	}
+	return nil
+}
+
+// discoveredAt reports whether the device with fingerprint is currently discovered, or was last seen, at ip.
+func discoveredAt(fingerprint, ip string) bool {
+	if item, ok := share.GetUserScanCurrent(fingerprint); ok && tool.SameIP(item.Ipaddress, ip) {
+		return true
+	}
+	known := share.KnownDeviceIP(fingerprint)
+	return known != "" && tool.SameIP(known, ip)
+}
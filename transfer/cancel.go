@@ -28,6 +28,7 @@ func CancelSession(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessio
 	if err != nil {
 		return fmt.Errorf("failed to create cancel request: %v", err)
 	}
+	req = req.WithContext(tool.WithPeerFingerprint(req.Context(), remote.Fingerprint))
 
 	client := tool.GetHttpClient()
 	resp, err := client.Do(req)
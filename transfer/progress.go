@@ -0,0 +1,309 @@
+package transfer
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/notify"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	// progressSampleInterval is how often throughput and ETA are recomputed while bytes flow.
+	progressSampleInterval = 500 * time.Millisecond
+	// progressNotifyInterval throttles upload_progress notifications per session.
+	progressNotifyInterval = time.Second
+	// progressKeepFinished keeps ended sessions pollable, so a poller sees the final state.
+	progressKeepFinished = time.Minute
+)
+
+// rateMeter smooths throughput over samples of at least progressSampleInterval.
+type rateMeter struct {
+	sampleAt    time.Time
+	sampleBytes int64
+	rate        float64 // bytes per second
+}
+
+// reset starts a new sample at done bytes, e.g. when a file (re)starts at an offset.
+func (m *rateMeter) reset(now time.Time, done int64) {
+	m.sampleAt, m.sampleBytes = now, done
+}
+
+// update folds the bytes since the last sample into the rate once the sample is long enough.
+func (m *rateMeter) update(now time.Time, done int64) {
+	elapsed := now.Sub(m.sampleAt)
+	if elapsed < progressSampleInterval {
+		return
+	}
+	current := float64(max(done-m.sampleBytes, 0)) / elapsed.Seconds()
+	if m.rate == 0 {
+		m.rate = current
+	} else {
+		m.rate = 0.7*m.rate + 0.3*current
+	}
+	m.reset(now, done)
+}
+
+// eta returns the seconds left for the remaining bytes, or -1 while the rate is unknown.
+func (m *rateMeter) eta(remaining int64) int64 {
+	if remaining <= 0 {
+		return 0
+	}
+	if m.rate < 1 {
+		return -1
+	}
+	return int64(float64(remaining)/m.rate + 0.5)
+}
+
+type progressFile struct {
+	types.FileProgress
+	meter rateMeter
+}
+
+type progressSession struct {
+	types.TransferProgress
+	files      map[string]*progressFile
+	order      []string
+	meter      rateMeter
+	notifiedAt time.Time
+	endedAt    time.Time
+	// pendingNotice is the latest upload_progress not sent yet; notifying tells whether a sender is running.
+	pendingNotice *progressNotice
+	notifying     bool
+}
+
+type progressNotice struct {
+	progress types.TransferProgress
+	file     types.FileProgress
+}
+
+var (
+	progressMu sync.Mutex
+	// progressSessions holds the progress per direction + "/" + sessionId
+	progressSessions = make(map[string]*progressSession)
+)
+
+func progressKey(direction, sessionId string) string {
+	return direction + "/" + sessionId
+}
+
+// StartProgress starts tracking the bytes of files of a session sent to or received from peer.
+func StartProgress(direction, sessionId, peer string, files map[string]types.FileInfo) {
+	now := time.Now()
+	session := &progressSession{
+		TransferProgress: types.TransferProgress{
+			SessionId:  sessionId,
+			Direction:  direction,
+			Peer:       peer,
+			State:      types.ProgressActive,
+			EtaSeconds: -1,
+			StartedAt:  now,
+			UpdatedAt:  now,
+		},
+		files: make(map[string]*progressFile, len(files)),
+		order: make([]string, 0, len(files)),
+	}
+	for fileId, info := range files {
+		session.files[fileId] = &progressFile{FileProgress: types.FileProgress{
+			FileId:     fileId,
+			FileName:   info.FileName,
+			State:      types.ProgressPending,
+			BytesTotal: max(info.Size, 0),
+			EtaSeconds: -1,
+		}}
+		session.BytesTotal += max(info.Size, 0)
+		session.order = append(session.order, fileId)
+	}
+	sort.Strings(session.order)
+	session.meter.reset(now, 0)
+
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	pruneProgressLocked(now)
+	progressSessions[progressKey(direction, sessionId)] = session
+}
+
+// TrackProgress returns r counting its bytes towards fileId of the session. offset is where r starts in the
+// file (resumed uploads). When the session is not tracked, r is returned as is.
+func TrackProgress(direction, sessionId, fileId string, offset int64, r io.Reader) io.Reader {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	session := progressSessions[progressKey(direction, sessionId)]
+	if session == nil || session.files[fileId] == nil {
+		return r
+	}
+	now := time.Now()
+	file := session.files[fileId]
+	session.BytesDone += max(offset, 0) - file.BytesDone
+	file.BytesDone = max(offset, 0)
+	file.State = types.ProgressActive
+	file.meter.reset(now, file.BytesDone)
+	session.meter.reset(now, session.BytesDone)
+	session.State = types.ProgressActive
+	session.UpdatedAt = now
+	return &progressReader{r: r, session: session, file: file}
+}
+
+// progressReader counts the bytes read from r.
+type progressReader struct {
+	r       io.Reader
+	session *progressSession
+	file    *progressFile
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		addProgress(pr.session, pr.file, int64(n))
+	}
+	return n, err
+}
+
+func addProgress(session *progressSession, file *progressFile, n int64) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	now := time.Now()
+	file.BytesDone += n
+	session.BytesDone += n
+	session.UpdatedAt = now
+	file.meter.update(now, file.BytesDone)
+	session.meter.update(now, session.BytesDone)
+	file.BytesPerSecond = int64(file.meter.rate)
+	file.EtaSeconds = file.meter.eta(file.BytesTotal - file.BytesDone)
+	session.BytesPerSecond = int64(session.meter.rate)
+	session.EtaSeconds = session.meter.eta(session.BytesTotal - session.BytesDone)
+	if now.Sub(session.notifiedAt) >= progressNotifyInterval {
+		session.notifiedAt = now
+		notifyProgressLocked(session, file)
+	}
+}
+
+// FinishFileProgress sets the final (done, failed) or interrupted state of a file. The session is finished,
+// with a last notification, once every file is done or failed.
+func FinishFileProgress(direction, sessionId, fileId, state string) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	session := progressSessions[progressKey(direction, sessionId)]
+	if session == nil || session.files[fileId] == nil {
+		return
+	}
+	now := time.Now()
+	file := session.files[fileId]
+	file.State = state
+	file.BytesPerSecond = 0
+	file.EtaSeconds = -1
+	if state == types.ProgressDone {
+		session.BytesDone += file.BytesTotal - file.BytesDone
+		file.BytesDone = file.BytesTotal
+		file.EtaSeconds = 0
+	}
+	session.UpdatedAt = now
+	for _, f := range session.files {
+		if f.State != types.ProgressDone && f.State != types.ProgressFailed {
+			return
+		}
+	}
+	session.State = types.ProgressFinished
+	session.BytesPerSecond = 0
+	session.EtaSeconds = 0
+	session.endedAt = now
+	notifyProgressLocked(session, file)
+}
+
+// EndProgress marks a session that ended before all of its files were done (e.g. cancelled) as cancelled.
+func EndProgress(direction, sessionId string) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	session := progressSessions[progressKey(direction, sessionId)]
+	if session == nil || !session.endedAt.IsZero() {
+		return
+	}
+	session.State = types.ProgressCancelled
+	session.BytesPerSecond = 0
+	session.EtaSeconds = -1
+	session.endedAt = time.Now()
+	session.UpdatedAt = session.endedAt
+}
+
+// ListProgress returns the progress of the tracked sessions, filtered by direction and sessionId when not
+// empty, oldest first. Ended sessions stay listed for a minute.
+func ListProgress(direction, sessionId string) []types.TransferProgress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	pruneProgressLocked(time.Now())
+	list := make([]types.TransferProgress, 0, len(progressSessions))
+	for _, session := range progressSessions {
+		if (direction != "" && session.Direction != direction) || (sessionId != "" && session.SessionId != sessionId) {
+			continue
+		}
+		list = append(list, snapshotProgressLocked(session, true))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+	return list
+}
+
+func pruneProgressLocked(now time.Time) {
+	for key, session := range progressSessions {
+		if !session.endedAt.IsZero() && now.Sub(session.endedAt) > progressKeepFinished {
+			delete(progressSessions, key)
+		} else if session.endedAt.IsZero() && now.Sub(session.UpdatedAt) > tool.DefaultTTL {
+			// Abandoned without an end, like the session it belonged to.
+			delete(progressSessions, key)
+		}
+	}
+}
+
+func snapshotProgressLocked(session *progressSession, withFiles bool) types.TransferProgress {
+	snapshot := session.TransferProgress
+	if withFiles {
+		snapshot.Files = make([]types.FileProgress, 0, len(session.order))
+		for _, fileId := range session.order {
+			snapshot.Files = append(snapshot.Files, session.files[fileId].FileProgress)
+		}
+	}
+	return snapshot
+}
+
+// notifyProgressLocked sends an upload_progress notification for session, with file as the current file.
+// One sender per session sends them in order; a notification not sent yet is replaced by a newer one.
+func notifyProgressLocked(session *progressSession, file *progressFile) {
+	session.pendingNotice = &progressNotice{progress: snapshotProgressLocked(session, false), file: file.FileProgress}
+	if !session.notifying {
+		session.notifying = true
+		go sendProgressNotices(session)
+	}
+}
+
+// sendProgressNotices sends the pending notifications of session until none is left.
+func sendProgressNotices(session *progressSession) {
+	for {
+		progressMu.Lock()
+		notice := session.pendingNotice
+		session.pendingNotice = nil
+		if notice == nil {
+			session.notifying = false
+			progressMu.Unlock()
+			return
+		}
+		progressMu.Unlock()
+
+		progress := notice.progress
+		if err := notify.SendUploadNotification(types.NotifyTypeUploadProgress, progress.SessionId, notice.file.FileId, map[string]any{
+			"direction":      progress.Direction,
+			"peer":           progress.Peer,
+			"state":          progress.State,
+			"bytesDone":      progress.BytesDone,
+			"bytesTotal":     progress.BytesTotal,
+			"bytesPerSecond": progress.BytesPerSecond,
+			"etaSeconds":     progress.EtaSeconds,
+			"file":           notice.file,
+		}); err != nil {
+			tool.DefaultLogger.Debugf("[Notify] Failed to send upload_progress notification: %v", err)
+		}
+	}
+}
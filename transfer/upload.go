@@ -19,7 +19,8 @@ func UploadFile(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId
 
 // UploadFileWithContext sends file data to the receiver with context support for cancellation.
 // Uses sessionId, fileId, and token from /prepare-upload response.
-func UploadFileWithContext(ctx context.Context, targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string, data io.Reader) error {
+// Bytes are counted towards the session progress when it was registered with StartProgress.
+func UploadFileWithContext(ctx context.Context, targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string, data io.Reader) (err error) {
 	if targetAddr == nil || remote == nil {
 		return fmt.Errorf("invalid parameters: targetAddr and remote must not be nil")
 	}
@@ -30,6 +31,16 @@ func UploadFileWithContext(ctx context.Context, targetAddr *net.UDPAddr, remote
 		return fmt.Errorf("invalid parameters: data must not be nil")
 	}
 
+	defer func() {
+		state := types.ProgressDone
+		if err != nil {
+			state = types.ProgressFailed
+		}
+		FinishFileProgress(types.TransferOutbound, sessionId, fileId, state)
+	}()
+	data = TrackProgress(types.TransferOutbound, sessionId, fileId, 0,
+		tool.LimitReader(ctx, data, types.TransferOutbound, remote.Fingerprint, targetAddr.IP.String()))
+
 	// Check if already cancelled
 	select {
 	case <-ctx.Done():
@@ -43,7 +54,7 @@ func UploadFileWithContext(ctx context.Context, targetAddr *net.UDPAddr, remote
 	}
 
 	// Create request with context for cancellation support
-	req, err := http.NewRequestWithContext(ctx, "POST", url, data)
+	req, err := http.NewRequestWithContext(tool.WithPeerFingerprint(ctx, remote.Fingerprint), "POST", url, data)
 	if err != nil {
 		return fmt.Errorf("failed to create upload request: %v", err)
 	}
@@ -0,0 +1,17 @@
+package types
+
+// BandwidthConfig limits transfer speed (bandwidth config key, GET/PUT /api/self/v1/bandwidth). Rates are bytes per
+// second, 0 = unlimited. A transfer is held to every limit that applies to it; changes apply to running transfers.
+type BandwidthConfig struct {
+	TotalBytesPerSecond   int64                `yaml:"totalBytesPerSecond,omitempty" json:"totalBytesPerSecond"`     // sending and receiving together
+	SendBytesPerSecond    int64                `yaml:"sendBytesPerSecond,omitempty" json:"sendBytesPerSecond"`       // all outbound transfers
+	ReceiveBytesPerSecond int64                `yaml:"receiveBytesPerSecond,omitempty" json:"receiveBytesPerSecond"` // all inbound transfers
+	Peers                 []PeerBandwidthLimit `yaml:"peers,omitempty" json:"peers"`
+}
+
+// PeerBandwidthLimit limits the transfers with one peer, shared by all of its sessions.
+type PeerBandwidthLimit struct {
+	Peer                  string `yaml:"peer" json:"peer"` // fingerprint or IP address
+	SendBytesPerSecond    int64  `yaml:"sendBytesPerSecond,omitempty" json:"sendBytesPerSecond"`
+	ReceiveBytesPerSecond int64  `yaml:"receiveBytesPerSecond,omitempty" json:"receiveBytesPerSecond"`
+}
@@ -15,6 +15,15 @@ type AppConfig struct {
 	KeyPEM                string                `yaml:"keyPEM,omitempty"`
 	AutoSaveFromFavorites bool                  `yaml:"autoSaveFromFavorites,omitempty"`
 	FavoriteDevices       []FavoriteDeviceEntry `yaml:"favoriteDevices,omitempty"`
+	ScanTargets           []string              `yaml:"scanTargets,omitempty"`     // extra HTTP scan targets: CIDR, range or single IPv4
+	StaticPeers           []StaticPeerEntry     `yaml:"staticPeers,omitempty"`     // peers polled via /info, see StaticPeerEntry
+	ScanMode              string                `yaml:"scanMode,omitempty"`        // udp|http|mixed|mdns|off, overridden by -scanMode
+	ReceivePolicy         []ReceivePolicyRule   `yaml:"receivePolicy,omitempty"`   // ordered accept/reject/ask rules; unmatched requests use autoSave settings
+	ReceiveRouting        ReceiveRoutingConfig  `yaml:"receiveRouting,omitempty"`  // destination folders of received files
+	ReceiveQuota          ReceiveQuotaConfig    `yaml:"receiveQuota,omitempty"`    // free-space reserve and daily quotas for received files
+	InboundSessions       InboundSessionsConfig `yaml:"inboundSessions,omitempty"` // concurrent receive session limits
+	Bandwidth             BandwidthConfig       `yaml:"bandwidth,omitempty"`       // send/receive rate limits, changeable at runtime
+	ReceiveHooks          ReceiveHooksConfig    `yaml:"receiveHooks,omitempty"`    // commands and webhooks run after files or text are received
 }
 
 // ProgramConfig holds runtime program configuration (pin, auto-save, etc.)
@@ -29,16 +38,25 @@ type Config struct {
 	Log                    string
 	UseMultcastAddress     string
 	UseMultcastPort        int
+	UseMultcastAddressV6   string // override IPv6 multicast group (default ff02::167)
+	UseIPv6Multicast       string // IPv6 multicast discovery: "" off, "*" all interfaces, or comma-separated names
+	UseBroadcast           string // broadcast discovery fallback: "" off, "*" all interfaces, or comma-separated names
 	UseConfigPath          string
 	UseDefaultUploadFolder string
 	UseReferNetworkInterface string // fixes when using virtual network interface. e.g. Clash TUN.
 	UsePin                 string
 	UseAutoSave            bool // if false, user require to confirm before recv.
 	UseAutoSaveFromFavorites bool // if true and useAutoSave is false, auto-accept from favorite devices only.
+	InsecureSkipPeerVerify bool // if true, peer certificates are not checked against fingerprints and favorites are not pinned.
 	UseAlias               string
 	SkipNotify             bool   // if true, skip notify mode.
 	UseHttp                bool   // if true, use http protocol; if false, use https protocol. Alias for protocol config.
 	ScanTimeout            int    // scan timeout in seconds, default 500. After timeout, auto scan will stop.
+	HeartbeatInterval      int    // /info heartbeat interval in seconds for known devices, 0 disables it.
+	ProbeStrategy          string // host probe before HTTP register: auto|icmp|tcp|arp|none
+	UseMDNS                bool   // if true, discover via mDNS / DNS-SD instead of UDP multicast and HTTP scanning
+	ScanMode               string // udp|http|mixed|mdns|off; empty uses the scanMode config key (default mixed)
+	RegisterResponseWindow int    // seconds a peer answered with /register is not answered again, default 60. 0 answers every announcement.
 	UseDownload            bool   // if true, enable download API (prepare-download, download, download page)
 	UseWebOutPath          string // path to Next.js static export output (default: web/out)
 	DoNotMakeSessionFolder bool   // if true, do not make any session folder, if meet same files
@@ -22,9 +22,21 @@ type DeviceInfoReverseMode struct {
 	Download    bool   `json:"download,omitempty"`    // If download API (5.2, 5.3) is active (optional, default: false)
 }
 
+// Discovery sources recorded in UserScanCurrentItem.Source.
+const (
+	DeviceSourceUDP        = "udp"         // multicast announcement
+	DeviceSourceHTTPScan   = "http_scan"   // HTTP subnet scan
+	DeviceSourceRegister   = "register"    // remote called our /register
+	DeviceSourceFastSender = "fast_sender" // fetched by IP for prepare-upload
+	DeviceSourceStatic     = "static"      // configured static peer polled via /info
+	DeviceSourceMDNS       = "mdns"        // mDNS / DNS-SD response
+)
+
 // UserScanCurrentItem holds discovered device info with IP address
 type UserScanCurrentItem struct {
 	Ipaddress string `json:"ip_address"`
+	LastSeen  int64  `json:"last_seen"` // unix milliseconds of the last announcement, register or heartbeat
+	Source    string `json:"source"`    // how the device was last discovered; use DeviceSourceXxx constants
 	VersionMessage
 }
 
@@ -36,4 +48,3 @@ type SelfNetworkInfo struct {
 	Number        string `json:"number"`         // number
 	NumberInt     int    `json:"number_int"`     // number int
 }
-
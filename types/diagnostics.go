@@ -0,0 +1,53 @@
+package types
+
+// DiscoveryInterfaceStats counts discovery traffic of one listener: an interface on one channel
+// (ipv4, ipv6, broadcast or mdns). IPv4 multicast is sent on the "default" route and broadcast on "*".
+type DiscoveryInterfaceStats struct {
+	Interface    string `json:"interface"`
+	Channel      string `json:"channel"`             // ipv4|ipv6|broadcast|mdns
+	Joined       bool   `json:"joined"`              // multicast group joined (or broadcast socket bound) and listening
+	JoinError    string `json:"joinError,omitempty"` // last join failure
+	LastReceived int64  `json:"lastReceived"`        // unix milliseconds of the last packet received, 0 = never
+	LastSent     int64  `json:"lastSent"`            // unix milliseconds of the last packet sent, 0 = never
+	Parsed       uint64 `json:"parsed"`              // packets from other devices that were handled
+	Ignored      uint64 `json:"ignored"`             // own packets, duplicates and packets not meant for us
+	Invalid      uint64 `json:"invalid"`             // packets that could not be parsed
+	Sent         uint64 `json:"sent"`                // packets sent
+	SendErrors   uint64 `json:"sendErrors"`          // failed sends
+}
+
+// HTTPScanDiagnostics is the HTTP scan sweep progress with its coverage and hit rate.
+type HTTPScanDiagnostics struct {
+	ScanProgress
+	Coverage float64 `json:"coverage"` // scanned / targets of the current sweep (0..1)
+	HitRate  float64 `json:"hitRate"`  // found / scanned of the current sweep (0..1)
+}
+
+// AutoScanRunningStatus is the number of running auto scan loops per kind.
+type AutoScanRunningStatus struct {
+	HTTP int `json:"http"`
+	UDP  int `json:"udp"`
+	MDNS int `json:"mdns"`
+}
+
+// DiscoverySelfTest is the result of sending an announcement and listening for it on our own listeners.
+type DiscoverySelfTest struct {
+	Sent       bool     `json:"sent"`
+	Error      string   `json:"error,omitempty"`
+	Received   bool     `json:"received"`
+	ReceivedOn []string `json:"receivedOn,omitempty"` // listeners that received it, e.g. "eth0/ipv4"
+	LatencyMs  int64    `json:"latencyMs,omitempty"`  // time until the first listener received it
+}
+
+// DiscoveryDiagnostics is returned by GET /api/self/v1/diagnostics/discovery.
+type DiscoveryDiagnostics struct {
+	Mode            string                    `json:"mode"` // udp|http|mixed|mdns|off
+	ScanPaused      bool                      `json:"scanPaused"`
+	ScanPauseCount  int32                     `json:"scanPauseCount"` // transfers currently pausing the scan
+	AutoScanRunning AutoScanRunningStatus     `json:"autoScanRunning"`
+	Interfaces      []DiscoveryInterfaceStats `json:"interfaces"`
+	HTTPScan        HTTPScanDiagnostics       `json:"httpScan"`
+	Probe           ProbeStatus               `json:"probe"`
+	Register        RegisterResponseStats     `json:"register"`
+	SelfTest        *DiscoverySelfTest        `json:"selfTest,omitempty"` // nil when skipped (?selfTest=false)
+}
@@ -4,6 +4,7 @@ package types
 type FavoriteDeviceEntry struct {
 	Fingerprint string `yaml:"favorite_fingerprint" json:"favorite_fingerprint"`
 	Alias       string `yaml:"favorite_alias" json:"favorite_alias"`
+	CertSHA256  string `yaml:"favorite_cert_sha256,omitempty" json:"favorite_cert_sha256,omitempty"` // certificate pinned on first verified contact
 }
 
 // FavoriteDevicesYamlFileConfig is used for YAML unmarshaling of favorites
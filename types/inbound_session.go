@@ -0,0 +1,42 @@
+package types
+
+import "time"
+
+// InboundSessionsConfig limits how many upload sessions are received at once (inboundSessions config key).
+// 0 disables a limit. A session counts from prepare-upload (while the user is asked) until its last file is done.
+type InboundSessionsConfig struct {
+	MaxSessions       int `yaml:"maxSessions,omitempty" json:"maxSessions,omitempty"`             // sessions from all senders; over it prepare-upload gets 409
+	MaxPerSender      int `yaml:"maxPerSender,omitempty" json:"maxPerSender,omitempty"`           // sessions per sender (same fingerprint or IP); over it 429
+	RetryAfterSeconds int `yaml:"retryAfterSeconds,omitempty" json:"retryAfterSeconds,omitempty"` // Retry-After hint sent with 409/429; default 30
+}
+
+const (
+	InboundSessionPending   = "pending"   // waiting for the user to confirm
+	InboundSessionReceiving = "receiving" // accepted, files are being uploaded
+
+	InboundFilePending   = "pending"
+	InboundFileReceiving = "receiving"
+	InboundFileDone      = "done"
+	InboundFileFailed    = "failed"
+)
+
+// InboundSession is an upload session being received, as listed by /api/self/v1/inbound-sessions.
+type InboundSession struct {
+	SessionId    string               `json:"sessionId"`
+	Alias        string               `json:"alias"`
+	Fingerprint  string               `json:"fingerprint"`
+	IP           string               `json:"ip"`
+	State        string               `json:"state"` // pending or receiving
+	Files        []InboundSessionFile `json:"files"`
+	StartedAt    time.Time            `json:"startedAt"`
+	LastActivity time.Time            `json:"lastActivity"`
+}
+
+// InboundSessionFile is one offered (pending state) or accepted (receiving state) file of an InboundSession.
+type InboundSessionFile struct {
+	FileId   string `json:"fileId"`
+	FileName string `json:"fileName"`
+	FileType string `json:"fileType"`
+	Size     int64  `json:"size"`
+	State    string `json:"state"` // pending, receiving, done or failed
+}
@@ -0,0 +1,28 @@
+package types
+
+// KnownDevice is a device of the persisted known-devices registry. Unlike UserScanCurrentItem it survives
+// restarts and does not expire; Online tells whether the device is currently in scan-current.
+type KnownDevice struct {
+	Fingerprint      string   `yaml:"fingerprint" json:"fingerprint"`
+	Alias            string   `yaml:"alias" json:"alias"`
+	AliasHistory     []string `yaml:"aliasHistory,omitempty" json:"alias_history,omitempty"` // previous aliases, oldest first
+	IPAddress        string   `yaml:"ipAddress" json:"ip_address"`                           // last known IP address
+	Port             int      `yaml:"port" json:"port"`
+	Protocol         string   `yaml:"protocol" json:"protocol"`
+	DeviceModel      string   `yaml:"deviceModel,omitempty" json:"deviceModel,omitempty"`
+	DeviceType       string   `yaml:"deviceType,omitempty" json:"deviceType,omitempty"`
+	Version          string   `yaml:"version,omitempty" json:"version,omitempty"`
+	Source           string   `yaml:"source,omitempty" json:"source,omitempty"`            // how the device was last discovered
+	FirstSeen        int64    `yaml:"firstSeen" json:"first_seen"`                         // unix milliseconds
+	LastSeen         int64    `yaml:"lastSeen" json:"last_seen"`                           // unix milliseconds
+	SentSessions     int      `yaml:"sentSessions,omitempty" json:"sent_sessions"`         // upload sessions we sent to the device
+	SentFiles        int      `yaml:"sentFiles,omitempty" json:"sent_files"`               // files accepted by the device
+	ReceivedSessions int      `yaml:"receivedSessions,omitempty" json:"received_sessions"` // upload sessions accepted from the device
+	ReceivedFiles    int      `yaml:"receivedFiles,omitempty" json:"received_files"`
+	Online           bool     `yaml:"-" json:"online"`
+}
+
+// KnownDevicesFile is the on-disk layout of the known-devices registry (known_devices.yaml next to config.yaml).
+type KnownDevicesFile struct {
+	Devices []KnownDevice `yaml:"devices"`
+}
@@ -5,13 +5,16 @@ package types
 const (
 	NotifyTypeUploadStart      = "upload_start"
 	NotifyTypeUploadEnd        = "upload_end"
+	NotifyTypeUploadProgress   = "upload_progress" // throttled byte progress of a running session, see TransferProgress
 	NotifyTypeConfirmRecv      = "confirm_recv"
 	NotifyTypeConfirmDownload  = "confirm_download"
 	NotifyTypePinRequired      = "pin_required"
 	NotifyTypeDeviceDiscovered = "device_discovered"
 	NotifyTypeDeviceUpdated    = "device_updated"
+	NotifyTypeDeviceLost       = "device_lost"
 	NotifyTypeInfo             = "info"
 	NotifyTypeTextReceived     = "text_received"
+	NotifyTypePeerCertificate  = "peer_certificate_mismatch" // a peer's certificate does not match its fingerprint or pin
 )
 
 // Notification represents a notification message structure sent via Unix socket (e.g. to Decky).
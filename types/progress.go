@@ -0,0 +1,48 @@
+package types
+
+import "time"
+
+// Transfer directions of TransferProgress.
+const (
+	TransferInbound  = "inbound"  // files received from a peer
+	TransferOutbound = "outbound" // files sent through the self API
+)
+
+// Progress states of a TransferProgress (active, finished, cancelled) and of its files (pending, active,
+// interrupted, done, failed).
+const (
+	ProgressPending     = "pending"
+	ProgressActive      = "active"
+	ProgressInterrupted = "interrupted" // the body ended early; the sender may resume
+	ProgressDone        = "done"
+	ProgressFailed      = "failed"
+	ProgressFinished    = "finished" // every file is done or failed
+	ProgressCancelled   = "cancelled"
+)
+
+// TransferProgress is the byte-level progress of one session, as polled from /api/self/v1/transfer-progress
+// and sent (without files) in upload_progress notifications. Throughput is smoothed; EtaSeconds is -1 while unknown.
+type TransferProgress struct {
+	SessionId      string         `json:"sessionId"`
+	Direction      string         `json:"direction"`
+	Peer           string         `json:"peer"` // alias of the sender (inbound) or receiver (outbound)
+	State          string         `json:"state"`
+	BytesDone      int64          `json:"bytesDone"`
+	BytesTotal     int64          `json:"bytesTotal"`
+	BytesPerSecond int64          `json:"bytesPerSecond"`
+	EtaSeconds     int64          `json:"etaSeconds"`
+	StartedAt      time.Time      `json:"startedAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	Files          []FileProgress `json:"files,omitempty"`
+}
+
+// FileProgress is the byte-level progress of one file of a TransferProgress.
+type FileProgress struct {
+	FileId         string `json:"fileId"`
+	FileName       string `json:"fileName"`
+	State          string `json:"state"`
+	BytesDone      int64  `json:"bytesDone"`
+	BytesTotal     int64  `json:"bytesTotal"`
+	BytesPerSecond int64  `json:"bytesPerSecond"`
+	EtaSeconds     int64  `json:"etaSeconds"`
+}
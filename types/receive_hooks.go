@@ -0,0 +1,47 @@
+package types
+
+// Receive hook events.
+const (
+	ReceiveHookSessionCompleted = "session_completed" // every file of a session is done (upload_end)
+	ReceiveHookFileReceived     = "file_received"     // one file was saved
+	ReceiveHookTextReceived     = "text_received"     // a text-only message arrived
+)
+
+// ReceiveHooksConfig runs automation after files or text are received (receiveHooks config key).
+type ReceiveHooksConfig struct {
+	MaxConcurrent int           `yaml:"maxConcurrent,omitempty" json:"maxConcurrent,omitempty"` // hooks running at once; default 2
+	Hooks         []ReceiveHook `yaml:"hooks,omitempty" json:"hooks,omitempty"`
+}
+
+// ReceiveHook runs a local command or posts to a local HTTP endpoint on its events. Exactly one of Command and
+// Webhook is set. Commands get the payload as JSON on stdin and as LOCALSEND_* environment variables (with the
+// text cut to 4 KiB; the stdin payload has all of it).
+type ReceiveHook struct {
+	Name           string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Events         []string `yaml:"events" json:"events"`                                     // session_completed, file_received, text_received
+	Command        []string `yaml:"command,omitempty" json:"command,omitempty"`               // program and arguments, run without a shell
+	WorkDir        string   `yaml:"workDir,omitempty" json:"workDir,omitempty"`               // working directory of the command
+	Webhook        string   `yaml:"webhook,omitempty" json:"webhook,omitempty"`               // http(s) URL on localhost or a loopback address
+	TimeoutSeconds int      `yaml:"timeoutSeconds,omitempty" json:"timeoutSeconds,omitempty"` // default 60
+}
+
+// ReceiveHookPayload describes the event a hook runs for. It is the JSON body of webhooks and the stdin of commands.
+type ReceiveHookPayload struct {
+	Event             string            `json:"event"`
+	SessionId         string            `json:"sessionId,omitempty"`
+	SenderAlias       string            `json:"senderAlias"`
+	SenderFingerprint string            `json:"senderFingerprint,omitempty"`
+	SenderIP          string            `json:"senderIp,omitempty"`
+	FileId            string            `json:"fileId,omitempty"`   // file_received
+	FileName          string            `json:"fileName,omitempty"` // file_received, text_received
+	FileType          string            `json:"fileType,omitempty"`
+	Size              int64             `json:"size,omitempty"`
+	SavePath          string            `json:"savePath,omitempty"`  // file_received
+	SavePaths         map[string]string `json:"savePaths,omitempty"` // session_completed: fileId -> path
+	TotalFiles        int               `json:"totalFiles,omitempty"`
+	SuccessFiles      int               `json:"successFiles,omitempty"`
+	FailedFiles       int               `json:"failedFiles,omitempty"`
+	FailedFileIds     []string          `json:"failedFileIds,omitempty"`
+	Text              string            `json:"text,omitempty"` // text_received
+	Timestamp         int64             `json:"timestamp"`      // unix seconds
+}
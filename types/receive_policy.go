@@ -0,0 +1,37 @@
+package types
+
+// Receive policy actions.
+const (
+	ReceiveActionAccept       = "accept"
+	ReceiveActionReject       = "reject"
+	ReceiveActionAsk          = "ask"
+	ReceiveActionAcceptSubset = "accept-subset" // accept only the files matching mimeTypes/extensions
+)
+
+// ReceivePolicyRule is one entry of the receivePolicy config list. Rules are evaluated in order and the first
+// rule whose conditions all hold decides. Empty conditions always hold.
+//
+// mimeTypes/extensions must hold for every offered file, except with accept-subset where they select the
+// files to accept (the rule holds if at least one file matches).
+type ReceivePolicyRule struct {
+	Name         string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Fingerprints []string `yaml:"fingerprints,omitempty" json:"fingerprints,omitempty"` // sender fingerprints
+	Aliases      []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`           // sender alias patterns (case-insensitive, * and ?)
+	Senders      []string `yaml:"senders,omitempty" json:"senders,omitempty"`           // sender IP or CIDR
+	Favorite     *bool    `yaml:"favorite,omitempty" json:"favorite,omitempty"`         // sender is (true) or is not (false) a verified favorite
+	MinFiles     int      `yaml:"minFiles,omitempty" json:"minFiles,omitempty"`
+	MaxFiles     int      `yaml:"maxFiles,omitempty" json:"maxFiles,omitempty"`
+	MinTotalSize int64    `yaml:"minTotalSize,omitempty" json:"minTotalSize,omitempty"` // bytes
+	MaxTotalSize int64    `yaml:"maxTotalSize,omitempty" json:"maxTotalSize,omitempty"` // bytes
+	MimeTypes    []string `yaml:"mimeTypes,omitempty" json:"mimeTypes,omitempty"`       // e.g. image/*, application/pdf
+	Extensions   []string `yaml:"extensions,omitempty" json:"extensions,omitempty"`     // e.g. .apk, jpg
+	TimeOfDay    string   `yaml:"timeOfDay,omitempty" json:"timeOfDay,omitempty"`       // local time window "HH:MM-HH:MM", may wrap midnight
+	Action       string   `yaml:"action" json:"action"`                                 // accept|reject|ask|accept-subset
+}
+
+// ReceivePolicyDecision is the outcome of evaluating the receive policy for a prepare-upload request.
+type ReceivePolicyDecision struct {
+	Action        string   `json:"action"`                  // accept|reject|ask|accept-subset
+	Rule          string   `json:"rule"`                    // name (or "#<index>") of the matching rule, "default" when none matched
+	AcceptedFiles []string `json:"acceptedFiles,omitempty"` // file IDs accepted by accept-subset
+}
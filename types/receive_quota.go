@@ -0,0 +1,11 @@
+package types
+
+// ReceiveQuotaConfig limits how much is accepted before an upload starts (receiveQuota config key).
+// Sizes are bytes; 0 disables a limit. Daily counters reset at local midnight and are kept in memory.
+type ReceiveQuotaConfig struct {
+	ReserveBytes     int64  `yaml:"reserveBytes,omitempty" json:"reserveBytes,omitempty"`         // free space always left on the destination file system
+	WarnBelowBytes   int64  `yaml:"warnBelowBytes,omitempty" json:"warnBelowBytes,omitempty"`     // confirm_recv warns when less would be left; default 1 GiB
+	SenderDailyBytes int64  `yaml:"senderDailyBytes,omitempty" json:"senderDailyBytes,omitempty"` // bytes one sender (verified fingerprint, else IP) may send per day
+	DailyBytes       int64  `yaml:"dailyBytes,omitempty" json:"dailyBytes,omitempty"`             // bytes received from all senders per day
+	OnExceed         string `yaml:"onExceed,omitempty" json:"onExceed,omitempty"`                 // reject (default) or partial: accept the files that fit
+}
@@ -0,0 +1,29 @@
+package types
+
+// ReceiveRoutingConfig decides where received files are saved (receiveRouting config key).
+//
+// Path templates may use {sender} (alias), {fingerprint}, {ip}, {date} (YYYY-MM-DD) and {session}. Values are
+// sanitized so they cannot add path separators, and every resolved folder must stay under its root.
+type ReceiveRoutingConfig struct {
+	Path   string         `yaml:"path,omitempty" json:"path,omitempty"`     // folder template under the root; default {session} ("." with -doNotMakeSessionFolder)
+	Routes []ReceiveRoute `yaml:"routes,omitempty" json:"routes,omitempty"` // evaluated in order per file, the first match wins
+}
+
+// ReceiveRoute sends matching files to another root and/or folder template. Empty conditions always hold.
+type ReceiveRoute struct {
+	Name         string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Fingerprints []string `yaml:"fingerprints,omitempty" json:"fingerprints,omitempty"` // per-favorite override: only these verified favorites
+	Favorite     *bool    `yaml:"favorite,omitempty" json:"favorite,omitempty"`         // sender is (true) or is not (false) a verified favorite
+	MimeTypes    []string `yaml:"mimeTypes,omitempty" json:"mimeTypes,omitempty"`       // e.g. image/*
+	Extensions   []string `yaml:"extensions,omitempty" json:"extensions,omitempty"`     // e.g. .apk
+	Dir          string   `yaml:"dir,omitempty" json:"dir,omitempty"`                   // root folder, ~ is the home folder; empty = upload folder
+	Path         string   `yaml:"path,omitempty" json:"path,omitempty"`                 // folder template under dir; empty = ReceiveRoutingConfig.Path
+}
+
+// ReceiveDestination is the resolved folder of one received file.
+type ReceiveDestination struct {
+	Root          string `json:"root"`          // configured root the folder must stay under
+	Dir           string `json:"dir"`           // folder the file (and its relative path) is saved in
+	Route         string `json:"route"`         // name (or "#<index>") of the matching route, "default" when none matched
+	SessionUnique bool   `json:"sessionUnique"` // Dir is unique to the session; otherwise name clashes get numbered names
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"fmt"
+	"strings"
+)
+
 // ScanMode defines the scanning mode
 type ScanMode int
 
@@ -7,8 +12,37 @@ const (
 	ScanModeUDP   ScanMode = iota // UDP multicast only
 	ScanModeHTTP                  // HTTP scanning only (legacy mode)
 	ScanModeMixed                 // Both UDP and HTTP scanning
+	ScanModeMDNS                  // mDNS / DNS-SD advertisement and browsing (_localsend._tcp)
+	ScanModeOff                   // no scanning; the device only listens and stays discoverable
 )
 
+// scanModeNames are the names used by -scanMode, the scanMode config key and the scan-mode API.
+var scanModeNames = map[ScanMode]string{
+	ScanModeUDP:   "udp",
+	ScanModeHTTP:  "http",
+	ScanModeMixed: "mixed",
+	ScanModeMDNS:  "mdns",
+	ScanModeOff:   "off",
+}
+
+func (m ScanMode) String() string {
+	if name, ok := scanModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("ScanMode(%d)", int(m))
+}
+
+// ParseScanMode parses a scan mode name (udp|http|mixed|mdns|off, case-insensitive).
+func ParseScanMode(name string) (ScanMode, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for mode, modeName := range scanModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scan mode %q (want udp|http|mixed|mdns|off)", name)
+}
+
 // ScanConfig holds the current scan configuration for scan-now API
 type ScanConfig struct {
 	Mode        ScanMode
@@ -17,3 +51,55 @@ type ScanConfig struct {
 	Timeout     int // UDP timeout in seconds (from config, default 500). 0 means no timeout
 	HTTPTimeout int // HTTP timeout in seconds, 60. 0 means use Timeout for backward compat
 }
+
+// ScanProgress reports the state of the HTTP scan sweep. Large target lists are swept in chunks
+// across several auto scan ticks, so Scanned may lag Targets for a few ticks.
+type ScanProgress struct {
+	Running      bool  `json:"running"`      // a scan chunk is in flight
+	Targets      int   `json:"targets"`      // addresses in the current sweep
+	Scanned      int   `json:"scanned"`      // addresses probed so far in the current sweep
+	Found        int   `json:"found"`        // devices found so far in the current sweep
+	RatePPS      int   `json:"ratePps"`      // probe rate of the current chunk, 0 = unlimited
+	Sweeps       int   `json:"sweeps"`       // completed sweeps since startup
+	LastSweepEnd int64 `json:"lastSweepEnd"` // unix milliseconds of the last completed sweep
+}
+
+// UserScanTargetsRequest is the request body of PUT /api/self/v1/scan-targets.
+// Each target is a CIDR (10.0.0.0/22), a range (10.0.0.10-10.0.3.200) or a single IPv4 address.
+type UserScanTargetsRequest struct {
+	Targets []string `json:"targets"`
+}
+
+// ProbeStatus reports the host reachability probe used before HTTP register during legacy scanning.
+type ProbeStatus struct {
+	Requested string `json:"requested"` // probe strategy from -probeStrategy (may be "auto")
+	Strategy  string `json:"strategy"`  // probe strategy in use: icmp, tcp, arp or none
+	ICMP      bool   `json:"icmp"`      // unprivileged ICMP echo works
+	TCP       bool   `json:"tcp"`       // TCP connect is always available
+	ARP       bool   `json:"arp"`       // ARP cache is readable (/proc/net/arp)
+}
+
+// ScanModeStatus is returned by the scan-mode API.
+type ScanModeStatus struct {
+	Mode            string `json:"mode"`            // udp|http|mixed|mdns|off
+	AutoScanRunning bool   `json:"autoScanRunning"` // an auto scan loop is currently running
+}
+
+// UserScanModeRequest is the request body of PUT /api/self/v1/scan-mode.
+type UserScanModeRequest struct {
+	Mode string `json:"mode"` // udp|http|mixed|mdns|off
+}
+
+// RegisterResponseStats counts the /register answers to multicast announcements.
+// Answers are deduplicated per fingerprint, throttled within the response window and backed off after failures.
+type RegisterResponseStats struct {
+	WindowSeconds int    `json:"windowSeconds"` // a peer answered within this window is not answered again
+	Scheduled     uint64 `json:"scheduled"`     // answers scheduled
+	Sent          uint64 `json:"sent"`          // answers delivered over HTTP
+	Failed        uint64 `json:"failed"`        // answers whose HTTP register failed (UDP fallback may have worked)
+	Deduped       uint64 `json:"deduped"`       // announcements dropped because an answer was already pending
+	Throttled     uint64 `json:"throttled"`     // announcements dropped within the response window
+	BackedOff     uint64 `json:"backedOff"`     // announcements dropped while backing off after failures
+	Pending       int    `json:"pending"`       // answers currently scheduled or in flight
+	Tracked       int    `json:"tracked"`       // fingerprints with scheduler state
+}
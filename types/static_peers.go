@@ -0,0 +1,19 @@
+package types
+
+// StaticPeerEntry is a manually configured peer that is polled via /info instead of being discovered,
+// e.g. a device on another routed subnet that multicast and the subnet sweep cannot reach.
+type StaticPeerEntry struct {
+	Host        string `yaml:"host" json:"host"`                                   // IP address or host name
+	Port        int    `yaml:"port,omitempty" json:"port,omitempty"`               // 0 = default port 53317
+	Fingerprint string `yaml:"fingerprint,omitempty" json:"fingerprint,omitempty"` // expected fingerprint; a different device is not merged
+}
+
+// StaticPeerStatus is a static peer with the result of its last poll.
+type StaticPeerStatus struct {
+	StaticPeerEntry
+	Online      bool                 `json:"online"`
+	LastChecked int64                `json:"last_checked"`         // unix milliseconds of the last poll
+	LastSeen    int64                `json:"last_seen"`            // unix milliseconds of the last successful poll
+	LastError   string               `json:"last_error,omitempty"` // error of the last poll
+	Device      *UserScanCurrentItem `json:"device,omitempty"`     // device info of the last successful poll
+}
@@ -11,7 +11,8 @@ type PrepareUploadResponse struct {
 }
 
 type ConfirmResult struct {
-	Confirmed bool `json:"confirmed"`
+	Confirmed     bool     `json:"confirmed"`
+	AcceptedFiles []string `json:"acceptedFiles,omitempty"` // file IDs to receive; empty accepts every offered file
 }
 
 // used in https://github.com/localsend/protocol/tree/main?tab=readme-ov-file#5-reverse-file-transfer-http-aka-download-api